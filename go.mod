@@ -1,9 +1,12 @@
 module simlife
 
-go 1.14
+go 1.18
 
 require (
 	github.com/integrii/flaggy v1.4.4
 	github.com/jroimartin/gocui v0.5.0
 	github.com/logrusorgru/aurora v2.0.3+incompatible
+	github.com/nsf/termbox-go v1.1.1
 )
+
+require github.com/mattn/go-runewidth v0.0.9 // indirect
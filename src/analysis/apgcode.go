@@ -0,0 +1,156 @@
+package analysis
+
+import (
+	"sort"
+	"strings"
+)
+
+/*
+	The object codes in the apgcode format (the extended Wechsler format used by Catagolue)
+	the object is split to the horizontal strips of 5 rows, every column of the strip is encoded by a char 0-9a-v
+	(the bit N is set when the cell in the row N of the strip is live), the strips are separated by 'z',
+	the zero runs are shortened: w - 2 zeros, x - 3 zeros, y0-yz - 4-39 zeros, the trailing zeros of the strip are omitted
+	the canonical code is the shortest one (the lexicographically smallest of the same length) among
+	all rotations and reflections and all phases of the object
+	https://conwaylife.com/wiki/Apgcode
+*/
+
+const wechslerDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+//point is the cell coordinates
+type point struct {
+	x int
+	y int
+}
+
+//orientations are the rotations and reflections of the point in the bounding box of size w x h
+var orientations = []func(p point, w int, h int) point{
+	func(p point, w int, h int) point { return p },
+	func(p point, w int, h int) point { return point{w - 1 - p.x, p.y} },
+	func(p point, w int, h int) point { return point{p.x, h - 1 - p.y} },
+	func(p point, w int, h int) point { return point{w - 1 - p.x, h - 1 - p.y} },
+	func(p point, w int, h int) point { return point{p.y, p.x} },
+	func(p point, w int, h int) point { return point{h - 1 - p.y, p.x} },
+	func(p point, w int, h int) point { return point{p.y, w - 1 - p.x} },
+	func(p point, w int, h int) point { return point{h - 1 - p.y, w - 1 - p.x} },
+}
+
+//canonicalWechsler returns the smallest Wechsler code of the cells among all orientations
+func canonicalWechsler(cells []point) string {
+	cells, w, h := normalize(cells)
+	best := ""
+	transformed := make([]point, len(cells))
+	for i, o := range orientations {
+		tw, th := w, h
+		if i >= 4 {
+			//the diagonal reflections swap the width and the height
+			tw, th = h, w
+		}
+		for j, p := range cells {
+			transformed[j] = o(p, w, h)
+		}
+		if code := wechsler(transformed, tw, th); i == 0 || lessCode(code, best) {
+			best = code
+		}
+	}
+	return best
+}
+
+//wechsler encodes the cells placed in the bounding box of size w x h
+func wechsler(cells []point, w int, h int) string {
+	strips := make([][]int, (h+4)/5)
+	for i := range strips {
+		strips[i] = make([]int, w)
+	}
+	for _, p := range cells {
+		strips[p.y/5][p.x] |= 1 << uint(p.y%5)
+	}
+	var sb strings.Builder
+	for i, strip := range strips {
+		if i > 0 {
+			sb.WriteByte('z')
+		}
+		zeros := 0
+		for _, v := range strip {
+			if v == 0 {
+				zeros++
+				continue
+			}
+			writeZeros(&sb, zeros)
+			zeros = 0
+			sb.WriteByte(wechslerDigits[v])
+		}
+	}
+	return sb.String()
+}
+
+//writeZeros writes the run of the zero columns in the shortened form
+func writeZeros(sb *strings.Builder, n int) {
+	for n > 0 {
+		switch {
+		case n >= 4:
+			k := n
+			if k > 39 {
+				k = 39
+			}
+			sb.WriteByte('y')
+			sb.WriteByte(wechslerDigits[k-4])
+			n -= k
+		case n == 3:
+			sb.WriteByte('x')
+			n = 0
+		case n == 2:
+			sb.WriteByte('w')
+			n = 0
+		default:
+			sb.WriteByte('0')
+			n = 0
+		}
+	}
+}
+
+//lessCode compares the codes, the shorter code is smaller
+func lessCode(a string, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+//normalize returns the cells sorted by rows and moved so the bounding box starts at 0,0 and the bounding box size
+func normalize(cells []point) (norm []point, w int, h int) {
+	if len(cells) == 0 {
+		return nil, 0, 0
+	}
+	min, max := cells[0], cells[0]
+	for _, p := range cells {
+		if p.x < min.x {
+			min.x = p.x
+		}
+		if p.y < min.y {
+			min.y = p.y
+		}
+		if p.x > max.x {
+			max.x = p.x
+		}
+		if p.y > max.y {
+			max.y = p.y
+		}
+	}
+	norm = make([]point, len(cells))
+	for i, p := range cells {
+		norm[i] = point{p.x - min.x, p.y - min.y}
+	}
+	sortPoints(norm)
+	return norm, max.x - min.x + 1, max.y - min.y + 1
+}
+
+//sortPoints sorts the points by rows
+func sortPoints(points []point) {
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].y != points[j].y {
+			return points[i].y < points[j].y
+		}
+		return points[i].x < points[j].x
+	})
+}
@@ -0,0 +1,387 @@
+package analysis
+
+import (
+	"simlife/src/universe"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	The object census of the universe area
+	the live cells are segmented to the 8-connected components, the components closer than 3 cells are grouped
+	as they can interact, the groups evolving exactly as their components in isolation are split back
+	(the separate blocks, the blinkers of the traffic light), the rest are the single objects (the pulsar made of the separate bars)
+	every object is simulated in isolation to find its kind and period and encoded with the canonical apgcode,
+	so the same objects in different orientations and phases are counted together
+	the field edges are not taken into account, the objects crossing the torus edges are split
+*/
+
+//object kinds
+const (
+	KindStillLife  = "still life"
+	KindOscillator = "oscillator"
+	KindSpaceship  = "spaceship"
+	KindOther      = "other" //the object isn't repeated in MaxPeriod generations, dies or is too large
+)
+
+const (
+	MaxPeriod      = 64   //the maximum period of the identified oscillators and spaceships
+	maxObjectCells = 4096 //the larger objects are not simulated
+	interactRange  = 2    //the components with the cells closer than this distance are grouped
+)
+
+//Object is the object found in the area
+type Object struct {
+	Code   string  //the canonical apgcode
+	Name   string  //the common name, empty if the object is unknown
+	Kind   string  //one of the Kind constants
+	Period int     //the period of the oscillator or the spaceship, 1 for the still life, 0 for the others
+	Cells  [][]int //array of [x,y] coordinates of the object cells in the area
+}
+
+//CensusEntry is the number of the same objects
+type CensusEntry struct {
+	Code   string `json:"code"`
+	Name   string `json:"name,omitempty"`
+	Kind   string `json:"kind"`
+	Period int    `json:"period,omitempty"`
+	Cells  int    `json:"cells"` //the population of the object in the found phase
+	Count  int    `json:"count"`
+}
+
+//Census is the summary of the objects in the area
+type Census struct {
+	Rule      string        `json:"rule"`
+	LiveCells int           `json:"liveCells"`
+	Objects   int           `json:"objects"`
+	Entries   []CensusEntry `json:"entries"`
+}
+
+//shape is the object classification independent of its position
+type shape struct {
+	code   string
+	kind   string
+	period int
+	phases [][]point //the phases of the still life or the oscillator relative to the first phase origin
+}
+
+//analyzer classifies the objects, the shapes are cached as the soups consist of the same small objects
+type analyzer struct {
+	rule   universe.Rule
+	shapes map[string]*shape
+}
+
+//TakeCensus finds and counts the objects in the area, the common objects of the Conway's Game of Life are named
+func TakeCensus(a universe.Area, rule universe.Rule) Census {
+	c := Census{Rule: rule.String()}
+	entries := map[string]*CensusEntry{}
+	for _, o := range Objects(a, rule) {
+		c.Objects++
+		c.LiveCells += len(o.Cells)
+		e, ok := entries[o.Code]
+		if !ok {
+			e = &CensusEntry{Code: o.Code, Name: o.Name, Kind: o.Kind, Period: o.Period, Cells: len(o.Cells)}
+			entries[o.Code] = e
+		}
+		e.Count++
+	}
+	c.Entries = make([]CensusEntry, 0, len(entries))
+	for _, e := range entries {
+		c.Entries = append(c.Entries, *e)
+	}
+	sort.Slice(c.Entries, func(i, j int) bool {
+		if c.Entries[i].Count != c.Entries[j].Count {
+			return c.Entries[i].Count > c.Entries[j].Count
+		}
+		return lessCode(c.Entries[i].Code, c.Entries[j].Code)
+	})
+	return c
+}
+
+//Objects segments the area to the objects
+func Objects(a universe.Area, rule universe.Rule) []Object {
+	an := &analyzer{rule: rule, shapes: map[string]*shape{}}
+	var names map[string]string
+	if rule == universe.ConwayRule {
+		names = knownNames()
+	}
+	objects := []Object{}
+	for _, group := range groups(a) {
+		for _, cells := range an.split(group) {
+			s, _ := an.classify(cells)
+			o := Object{Code: s.code, Name: names[s.code], Kind: s.kind, Period: s.period, Cells: make([][]int, len(cells))}
+			sortPoints(cells)
+			for i, p := range cells {
+				o.Cells[i] = []int{p.x, p.y}
+			}
+			objects = append(objects, o)
+		}
+	}
+	return objects
+}
+
+//groups returns the 8-connected components of the area live cells, the components which can interact are grouped
+func groups(a universe.Area) [][][]point {
+	labels := make([]int32, a.Width*a.Height)
+	components := [][]point{}
+	for y := range a.Entities {
+		for x, e := range a.Entities[y] {
+			if !e || labels[y*a.Width+x] != 0 {
+				continue
+			}
+			//flood fill, the labels are the component indexes + 1
+			label := int32(len(components) + 1)
+			labels[y*a.Width+x] = label
+			component := []point{{x, y}}
+			for i := 0; i < len(component); i++ {
+				p := component[i]
+				for ny := p.y - 1; ny <= p.y+1; ny++ {
+					for nx := p.x - 1; nx <= p.x+1; nx++ {
+						if nx < 0 || ny < 0 || nx >= a.Width || ny >= a.Height || !a.Entities[ny][nx] || labels[ny*a.Width+nx] != 0 {
+							continue
+						}
+						labels[ny*a.Width+nx] = label
+						component = append(component, point{nx, ny})
+					}
+				}
+			}
+			components = append(components, component)
+		}
+	}
+
+	parent := make([]int, len(components))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i, component := range components {
+		for _, p := range component {
+			for ny := p.y - interactRange; ny <= p.y+interactRange; ny++ {
+				for nx := p.x - interactRange; nx <= p.x+interactRange; nx++ {
+					if nx < 0 || ny < 0 || nx >= a.Width || ny >= a.Height || labels[ny*a.Width+nx] == 0 {
+						continue
+					}
+					if j := find(int(labels[ny*a.Width+nx]) - 1); j != find(i) {
+						parent[j] = find(i)
+					}
+				}
+			}
+		}
+	}
+	grouped := map[int][][]point{}
+	order := []int{}
+	for i, component := range components {
+		root := find(i)
+		if _, ok := grouped[root]; !ok {
+			order = append(order, root)
+		}
+		grouped[root] = append(grouped[root], component)
+	}
+	result := make([][][]point, 0, len(order))
+	for _, root := range order {
+		result = append(result, grouped[root])
+	}
+	return result
+}
+
+//split returns the components of the group as the separate objects if they don't interact, the whole group otherwise
+func (an *analyzer) split(group [][]point) [][]point {
+	merged := []point{}
+	for _, component := range group {
+		merged = append(merged, component...)
+	}
+	if len(group) == 1 {
+		return group
+	}
+	//the group evolves as the separate components if the components are stationary and
+	//the group generations are the union of the component generations
+	period := 1
+	phases := make([][][]point, len(group))
+	for i, component := range group {
+		s, origin := an.classify(component)
+		if s.phases == nil {
+			return [][]point{merged}
+		}
+		phases[i] = make([][]point, len(s.phases))
+		for j, phase := range s.phases {
+			phases[i][j] = move(phase, origin)
+		}
+		period = lcm(period, s.period)
+		if period > MaxPeriod {
+			period = MaxPeriod
+		}
+	}
+	cur := toSet(merged)
+	for gen := 1; gen <= period; gen++ {
+		cur = an.step(cur)
+		union := map[point]bool{}
+		for _, ph := range phases {
+			for _, p := range ph[gen%len(ph)] {
+				union[p] = true
+			}
+		}
+		if !sameSet(cur, union) {
+			return [][]point{merged}
+		}
+	}
+	return group
+}
+
+//classify simulates the cells in isolation, returns the object shape and the position of the object bounding box
+func (an *analyzer) classify(cells []point) (*shape, point) {
+	norm, _, _ := normalize(cells)
+	origin := point{cells[0].x, cells[0].y}
+	for _, p := range cells {
+		if p.x < origin.x {
+			origin.x = p.x
+		}
+		if p.y < origin.y {
+			origin.y = p.y
+		}
+	}
+	key := shapeKey(norm)
+	if s, ok := an.shapes[key]; ok {
+		return s, origin
+	}
+
+	s := &shape{kind: KindOther}
+	phases := [][]point{norm}
+	if len(norm) <= maxObjectCells && !an.rule.Birth(0) {
+		cur := toSet(norm)
+		for gen := 1; gen <= MaxPeriod && len(cur) > 0 && len(cur) <= maxObjectCells; gen++ {
+			cur = an.step(cur)
+			phase := fromSet(cur)
+			shifted, _, _ := normalize(phase)
+			if shapeKey(shifted) != key {
+				phases = append(phases, phase)
+				continue
+			}
+			s.period = gen
+			s.kind = KindSpaceship
+			sortPoints(phase)
+			if phase[0] == norm[0] {
+				s.kind = KindOscillator
+				if gen == 1 {
+					s.kind = KindStillLife
+				}
+				s.phases = phases
+			}
+			break
+		}
+	}
+
+	switch s.kind {
+	case KindStillLife:
+		s.code = "xs" + strconv.Itoa(len(norm)) + "_" + canonicalWechsler(norm)
+	case KindOscillator, KindSpaceship:
+		best := ""
+		for i, phase := range phases {
+			if code := canonicalWechsler(phase); i == 0 || lessCode(code, best) {
+				best = code
+			}
+		}
+		prefix := "xp"
+		if s.kind == KindSpaceship {
+			prefix = "xq"
+		}
+		s.code = prefix + strconv.Itoa(s.period) + "_" + best
+	default:
+		//the unidentified objects are distinguished by the population only, the codes of the large objects are too long
+		s.code = "ov_" + strconv.Itoa(len(norm))
+	}
+	an.shapes[key] = s
+	return s, origin
+}
+
+//step calculates the next generation of the cells on the infinite plane
+func (an *analyzer) step(cells map[point]bool) map[point]bool {
+	neighbours := make(map[point]int, len(cells)*4)
+	for p := range cells {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if dx != 0 || dy != 0 {
+					neighbours[point{p.x + dx, p.y + dy}]++
+				}
+			}
+		}
+	}
+	next := make(map[point]bool, len(cells))
+	for p, n := range neighbours {
+		if cells[p] && an.rule.Survival(n) || !cells[p] && an.rule.Birth(n) {
+			next[p] = true
+		}
+	}
+	//the isolated live cells have no neighbours counted
+	if an.rule.Survival(0) {
+		for p := range cells {
+			if _, ok := neighbours[p]; !ok {
+				next[p] = true
+			}
+		}
+	}
+	return next
+}
+
+//shapeKey returns the map key of the normalized cells
+func shapeKey(norm []point) string {
+	var sb strings.Builder
+	for _, p := range norm {
+		sb.WriteString(strconv.Itoa(p.x))
+		sb.WriteByte(',')
+		sb.WriteString(strconv.Itoa(p.y))
+		sb.WriteByte(';')
+	}
+	return sb.String()
+}
+
+func toSet(cells []point) map[point]bool {
+	set := make(map[point]bool, len(cells))
+	for _, p := range cells {
+		set[p] = true
+	}
+	return set
+}
+
+func fromSet(set map[point]bool) []point {
+	cells := make([]point, 0, len(set))
+	for p := range set {
+		cells = append(cells, p)
+	}
+	return cells
+}
+
+func sameSet(a map[point]bool, b map[point]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for p := range a {
+		if !b[p] {
+			return false
+		}
+	}
+	return true
+}
+
+//move returns the cells moved by the offset
+func move(cells []point, offset point) []point {
+	moved := make([]point, len(cells))
+	for i, p := range cells {
+		moved[i] = point{p.x + offset.x, p.y + offset.y}
+	}
+	return moved
+}
+
+func lcm(a int, b int) int {
+	x, y := a, b
+	for y != 0 {
+		x, y = y, x%y
+	}
+	return a / x * b
+}
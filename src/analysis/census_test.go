@@ -0,0 +1,117 @@
+package analysis
+
+import (
+	"simlife/src/pattern"
+	"simlife/src/universe"
+	"strings"
+	"testing"
+)
+
+func Test_Codes(t *testing.T) {
+	//the apgcodes of Catagolue
+	codes := map[string]string{
+		"block":          "xs4_33",
+		"beehive":        "xs6_696",
+		"loaf":           "xs7_2596",
+		"boat":           "xs5_253",
+		"ship":           "xs6_356",
+		"tub":            "xs4_252",
+		"pond":           "xs8_6996",
+		"blinker":        "xp2_7",
+		"toad":           "xp2_7e",
+		"beacon":         "xp2_318c",
+		"pentadecathlon": "xp15_4r4z4r4",
+		"glider":         "xq4_153",
+		"lwss":           "xq4_6frc",
+	}
+	found := map[string]string{}
+	for code, name := range knownNames() {
+		found[name] = code
+	}
+	for name, code := range codes {
+		if found[name] != code {
+			t.Fatalf("%s: the code %q, expected %q", name, found[name], code)
+		}
+	}
+	if len(found) != len(knownNames()) {
+		t.Fatalf("the objects have the same codes: %v", knownNames())
+	}
+}
+
+func Test_Census(t *testing.T) {
+	a := area(60, 60)
+	place(t, a, "block", 1, 1, false)
+	place(t, a, "block", 50, 1, false)
+	place(t, a, "beehive", 10, 1, false)
+	place(t, a, "beehive", 20, 1, true)
+	place(t, a, "blinker", 30, 1, false)
+	place(t, a, "blinker", 35, 1, true)
+	place(t, a, "pulsar", 2, 20, false)
+	place(t, a, "glider", 30, 30, false)
+	place(t, a, "loaf", 40, 40, true)
+	//the blocks at the distance 2 don't interact
+	place(t, a, "block", 50, 50, false)
+	place(t, a, "block", 54, 50, false)
+	//the unknown still life: two snakes touching
+	place(t, a, "snake", 20, 45, false)
+	place(t, a, "snake", 22, 47, true)
+
+	c := TakeCensus(a, universe.ConwayRule)
+	counts := map[string]int{}
+	for _, e := range c.Entries {
+		counts[e.Name] += e.Count
+	}
+	expected := map[string]int{"block": 4, "beehive": 2, "blinker": 2, "pulsar": 1, "glider": 1, "loaf": 1}
+	for name, n := range expected {
+		if counts[name] != n {
+			t.Fatalf("%s: %d objects, expected %d, census %+v", name, counts[name], n, c)
+		}
+	}
+	if counts[""] != 1 || c.Objects != 12 {
+		t.Fatalf("unexpected census %+v", c)
+	}
+	if c.Entries[0].Name != "block" || c.Entries[0].Kind != KindStillLife || c.Entries[0].Period != 1 {
+		t.Fatalf("unexpected first entry %+v", c.Entries[0])
+	}
+
+	//the objects aren't named for the other rules
+	c = TakeCensus(a, universe.MustParseRule("B36/S23"))
+	for _, e := range c.Entries {
+		if e.Name != "" {
+			t.Fatalf("the object %+v is named for %v", e, c.Rule)
+		}
+	}
+}
+
+func area(width int, height int) universe.Area {
+	a := universe.Area{Width: width, Height: height, Entities: make([][]universe.Cell, height)}
+	for y := range a.Entities {
+		a.Entities[y] = make([]universe.Cell, width)
+	}
+	return a
+}
+
+//place sets the cells of the named pattern at x,y, transposed if needed
+func place(t *testing.T, a universe.Area, name string, x int, y int, transpose bool) {
+	t.Helper()
+	rle := ""
+	for _, o := range commonObjects {
+		if o.name == name {
+			rle = o.rle
+		}
+	}
+	p, err := pattern.ReadRLE(strings.NewReader(rle))
+	if rle == "" {
+		p, err = pattern.Find(name)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range p.Cells {
+		cx, cy := c[0], c[1]
+		if transpose {
+			cx, cy = cy, cx
+		}
+		a.Entities[y+cy][x+cx] = true
+	}
+}
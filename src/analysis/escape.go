@@ -0,0 +1,302 @@
+package analysis
+
+import (
+	"fmt"
+	"simlife/src/universe"
+)
+
+/*
+	The detection of the escaping spaceships
+	every generation is segmented to the objects, the small objects are matched with the displaced copies
+	of the same shape in the recent generations, the moving object is confirmed as the spaceship by the simulation in isolation
+	the spaceship is tracked while it's moving, it's escaped when it's free and moves away from the reaction region
+	(the bounding box of the other objects), the generation of emission is the first generation it's found free
+	the detector keeps its own frames (the small objects of the last MaxShipPeriod generations) instead of reading
+	the universe undo history: the history is disabled in the run mode and keeps the area deltas only,
+	so every match would restore and segment the previous areas again, the frames are segmented once
+*/
+
+const (
+	MaxShipPeriod = 16 //the maximum period of the detected spaceships
+	maxShipCells  = 64 //the larger objects are not matched
+)
+
+//Escape is the spaceship leaving the reaction region
+type Escape struct {
+	Code       string `json:"code"`           //the canonical apgcode
+	Name       string `json:"name,omitempty"` //the common name, empty if the spaceship is unknown
+	Period     int    `json:"period"`
+	Direction  string `json:"direction"` //the compass direction, the north is the top of the field
+	DX         int    `json:"dx"`        //the displacement per period
+	DY         int    `json:"dy"`
+	Generation int    `json:"generation"` //the generation of emission
+}
+
+//box is the bounding box of the cells
+type box struct {
+	minX int
+	minY int
+	maxX int
+	maxY int
+}
+
+//frame is the small objects of the generation
+type frame struct {
+	generation int
+	objects    map[string][]point //the positions of the objects by their normalized shapes
+}
+
+//track is the moving spaceship
+type track struct {
+	code       string
+	box        box
+	generation int //the last generation the spaceship is found
+	emitted    int //the first generation the spaceship is found
+	escaped    bool
+}
+
+//EscapeDetector finds the spaceships leaving the reaction region in the sequence of the generations
+type EscapeDetector struct {
+	an      *analyzer
+	names   map[string]string
+	frames  []frame //the recent generations
+	tracks  []*track
+	escapes []Escape
+}
+
+//NewEscapeDetector creates the detector, the common spaceships of the Conway's Game of Life are named
+func NewEscapeDetector(rule universe.Rule) *EscapeDetector {
+	d := &EscapeDetector{an: &analyzer{rule: rule, shapes: map[string]*shape{}}}
+	if rule == universe.ConwayRule {
+		d.names = knownNames()
+	}
+	return d
+}
+
+//Reset forgets the generations and the escaped spaceships
+func (d *EscapeDetector) Reset() {
+	d.frames = nil
+	d.tracks = nil
+	d.escapes = nil
+}
+
+//Escapes returns the spaceships escaped since the start or the reset
+func (d *EscapeDetector) Escapes() []Escape {
+	return append([]Escape(nil), d.escapes...)
+}
+
+//Add analyses the generation, returns the spaceships escaped in this generation
+//the generations should be added in order, the detector is reset if the generation goes back (undo, clear),
+//the same generation replaces the previous one (the area is edited)
+//the skipped generations are allowed, the spaceships are found if their periods are multiples of the step
+func (d *EscapeDetector) Add(a universe.Area, generation int) []Escape {
+	if n := len(d.frames); n > 0 && generation <= d.frames[n-1].generation {
+		if generation < d.frames[n-1].generation {
+			d.Reset()
+		} else {
+			d.frames = d.frames[:n-1]
+		}
+	}
+
+	type ship struct {
+		s     *shape
+		box   box
+		delta point
+	}
+	f := frame{generation: generation, objects: map[string][]point{}}
+	boxes := []box{}
+	ships := map[int]ship{}
+	for _, group := range groups(a) {
+		cells := []point{}
+		for _, component := range group {
+			cells = append(cells, component...)
+		}
+		b := bounds(cells)
+		boxes = append(boxes, b)
+		if len(cells) > maxShipCells {
+			continue
+		}
+		norm, _, _ := normalize(cells)
+		key := shapeKey(norm)
+		origin := point{b.minX, b.minY}
+		f.objects[key] = append(f.objects[key], origin)
+		period, delta, ok := d.match(key, origin, generation)
+		if !ok {
+			continue
+		}
+		if s, _ := d.an.classify(cells); s.kind == KindSpaceship && s.period == period {
+			ships[len(boxes)-1] = ship{s, b, delta}
+		}
+	}
+	d.frames = append(d.frames, f)
+	for len(d.frames) > 0 && d.frames[0].generation < generation-MaxShipPeriod {
+		d.frames = d.frames[1:]
+	}
+
+	//the reaction region is the rest of the objects
+	region, hasRegion := box{}, false
+	for i, b := range boxes {
+		if _, ok := ships[i]; ok {
+			continue
+		}
+		if !hasRegion {
+			region, hasRegion = b, true
+		}
+		region = region.union(b)
+	}
+
+	escaped := []Escape{}
+	for i := range boxes {
+		sh, ok := ships[i]
+		if !ok {
+			continue
+		}
+		t := d.follow(sh.s.code, sh.box, generation, generation-sh.s.period)
+		if t.escaped {
+			continue
+		}
+		if hasRegion {
+			before := sh.box.move(-sh.delta.x, -sh.delta.y)
+			if gap := sh.box.gap(region); gap <= interactRange || gap <= before.gap(region) {
+				continue
+			}
+		}
+		t.escaped = true
+		e := Escape{
+			Code:       sh.s.code,
+			Name:       d.names[sh.s.code],
+			Period:     sh.s.period,
+			Direction:  direction(sh.delta),
+			DX:         sh.delta.x,
+			DY:         sh.delta.y,
+			Generation: t.emitted,
+		}
+		escaped = append(escaped, e)
+		d.escapes = append(d.escapes, e)
+	}
+
+	tracks := d.tracks[:0]
+	for _, t := range d.tracks {
+		if t.generation >= generation-MaxShipPeriod {
+			tracks = append(tracks, t)
+		}
+	}
+	d.tracks = tracks
+	return escaped
+}
+
+//match finds the displaced copy of the object in the recent generations, returns the period and the displacement
+func (d *EscapeDetector) match(key string, origin point, generation int) (int, point, bool) {
+	for i := len(d.frames) - 1; i >= 0; i-- {
+		f := d.frames[i]
+		period := generation - f.generation
+		if period > MaxShipPeriod {
+			break
+		}
+		for _, o := range f.objects[key] {
+			delta := point{origin.x - o.x, origin.y - o.y}
+			if delta != (point{}) && abs(delta.x) <= period && abs(delta.y) <= period {
+				return period, delta, true
+			}
+		}
+	}
+	return 0, point{}, false
+}
+
+//follow returns the track of the spaceship, the new track is started if the spaceship isn't tracked yet
+//the spaceship moves not faster than one cell per generation
+func (d *EscapeDetector) follow(code string, b box, generation int, emitted int) *track {
+	for _, t := range d.tracks {
+		if r := generation - t.generation; t.code == code && t.box.move(-r, -r).union(t.box.move(r, r)).overlaps(b) {
+			t.box, t.generation = b, generation
+			return t
+		}
+	}
+	t := &track{code: code, box: b, generation: generation, emitted: emitted}
+	d.tracks = append(d.tracks, t)
+	return t
+}
+
+//String returns the escape description
+func (e Escape) String() string {
+	name := e.Name
+	if name == "" {
+		name = e.Code
+	}
+	return fmt.Sprintf("%s moving %s emitted at generation %d", name, e.Direction, e.Generation)
+}
+
+//direction returns the compass direction of the displacement
+func direction(delta point) string {
+	d := ""
+	if delta.y < 0 {
+		d = "N"
+	} else if delta.y > 0 {
+		d = "S"
+	}
+	if delta.x > 0 {
+		d += "E"
+	} else if delta.x < 0 {
+		d += "W"
+	}
+	return d
+}
+
+//bounds returns the bounding box of the cells
+func bounds(cells []point) box {
+	b := box{cells[0].x, cells[0].y, cells[0].x, cells[0].y}
+	for _, p := range cells {
+		b = b.union(box{p.x, p.y, p.x, p.y})
+	}
+	return b
+}
+
+func (b box) union(o box) box {
+	if o.minX < b.minX {
+		b.minX = o.minX
+	}
+	if o.minY < b.minY {
+		b.minY = o.minY
+	}
+	if o.maxX > b.maxX {
+		b.maxX = o.maxX
+	}
+	if o.maxY > b.maxY {
+		b.maxY = o.maxY
+	}
+	return b
+}
+
+func (b box) move(dx int, dy int) box {
+	return box{b.minX + dx, b.minY + dy, b.maxX + dx, b.maxY + dy}
+}
+
+func (b box) overlaps(o box) bool {
+	return b.minX <= o.maxX && o.minX <= b.maxX && b.minY <= o.maxY && o.minY <= b.maxY
+}
+
+//gap returns the number of the cells between the boxes (the Chebyshev distance - 1), 0 if the boxes overlap
+func (b box) gap(o box) int {
+	gx, gy := 0, 0
+	if o.minX > b.maxX {
+		gx = o.minX - b.maxX - 1
+	} else if b.minX > o.maxX {
+		gx = b.minX - o.maxX - 1
+	}
+	if o.minY > b.maxY {
+		gy = o.minY - b.maxY - 1
+	} else if b.minY > o.maxY {
+		gy = b.minY - o.maxY - 1
+	}
+	if gx > gy {
+		return gx
+	}
+	return gy
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,60 @@
+package analysis
+
+import (
+	"simlife/src/pattern"
+	"simlife/src/universe"
+	"testing"
+)
+
+func Test_Escapes(t *testing.T) {
+	p, err := pattern.Find("gosper-gun")
+	if err != nil {
+		t.Fatal(err)
+	}
+	an := &analyzer{rule: universe.ConwayRule, shapes: map[string]*shape{}}
+	cells := map[point]bool{}
+	for _, c := range p.Cells {
+		cells[point{c[0] + 5, c[1] + 5}] = true
+	}
+	d := NewEscapeDetector(universe.ConwayRule)
+	escapes := []Escape{}
+	for gen := 0; gen <= 300; gen++ {
+		a := area(150, 150)
+		for c := range cells {
+			if c.x < a.Width && c.y < a.Height {
+				a.Entities[c.y][c.x] = true
+			}
+		}
+		escapes = append(escapes, d.Add(a, gen)...)
+		cells = an.step(cells)
+	}
+	if len(escapes) < 8 || len(escapes) != len(d.Escapes()) {
+		t.Fatalf("%d escapes found: %v", len(escapes), escapes)
+	}
+	for i, e := range escapes {
+		if e.Name != "glider" || e.Direction != "SE" || e.Period != 4 || e.DX != 1 || e.DY != 1 {
+			t.Fatalf("unexpected escape %v", e)
+		}
+		if i > 0 && e.Generation-escapes[i-1].Generation != 30 {
+			t.Fatalf("the gliders are emitted at %d and %d generations", escapes[i-1].Generation, e.Generation)
+		}
+	}
+
+	//the still lifes and the oscillators don't move
+	d.Add(area(150, 150), 0)
+	if len(d.Escapes()) != 0 {
+		t.Fatal("the detector isn't reset when the generation goes back")
+	}
+	for gen := 1; gen <= 20; gen++ {
+		a := area(30, 30)
+		place(t, a, "block", 5, 5, false)
+		if gen%2 == 0 {
+			place(t, a, "blinker", 15, 15, false)
+		} else {
+			place(t, a, "blinker", 16, 14, true)
+		}
+		if e := d.Add(a, gen); len(e) != 0 {
+			t.Fatalf("unexpected escapes %v", e)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package analysis
+
+import (
+	"simlife/src/pattern"
+	"simlife/src/universe"
+	"strings"
+	"sync"
+)
+
+//commonObjects are the common objects of the Conway's Game of Life soups which are not in the pattern catalog
+//https://catagolue.hatsya.com/census/b3s23/C1
+var commonObjects = []struct {
+	name string
+	rle  string
+}{
+	{"ship", "x = 3, y = 3\n2o$obo$b2o!"},
+	{"pond", "x = 4, y = 4\nb2o$o2bo$o2bo$b2o!"},
+	{"long boat", "x = 4, y = 4\nbo$obo$bobo$2b2o!"},
+	{"barge", "x = 4, y = 4\nbo$obo$bobo$2bo!"},
+	{"long ship", "x = 4, y = 4\n2o$obo$bobo$2b2o!"},
+	{"mango", "x = 5, y = 4\nb2o$o2bo$bo2bo$2b2o!"},
+	{"snake", "x = 4, y = 2\n2obo$ob2o!"},
+	{"aircraft carrier", "x = 4, y = 3\n2o$o2bo$2b2o!"},
+	{"eater 1", "x = 4, y = 4\n2o$obo$2bo$2b2o!"},
+	{"clock", "x = 4, y = 4\n2bo$obo$bobo$bo!"},
+}
+
+var (
+	namesOnce sync.Once
+	names     map[string]string //apgcode -> the object name
+)
+
+//knownNames returns the names of the common objects by their apgcodes
+func knownNames() map[string]string {
+	namesOnce.Do(func() {
+		an := &analyzer{rule: universe.ConwayRule, shapes: map[string]*shape{}}
+		names = map[string]string{}
+		add := func(name string, rle string) {
+			p, err := pattern.ReadRLE(strings.NewReader(rle))
+			if err != nil || len(p.Cells) == 0 {
+				return
+			}
+			cells := make([]point, len(p.Cells))
+			for i, c := range p.Cells {
+				cells[i] = point{c[0], c[1]}
+			}
+			if s, _ := an.classify(cells); s.kind != KindOther {
+				names[s.code] = name
+			}
+		}
+		for _, e := range pattern.Catalog() {
+			if e.Category == pattern.CategoryStillLife || e.Category == pattern.CategoryOscillator || e.Category == pattern.CategorySpaceship {
+				add(e.Name, e.RLE)
+			}
+		}
+		for _, o := range commonObjects {
+			add(o.name, o.rle)
+		}
+	})
+	return names
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/integrii/flaggy"
+	"io/ioutil"
+	"os"
+	"simlife/src/pattern"
 	"simlife/src/universe"
 	"simlife/src/view"
+	"strconv"
 	"strings"
-	"time"
+	"text/tabwriter"
 )
 
 var (
@@ -19,20 +24,58 @@ var (
 		{5, 3},
 	}
 
-	engines = map[string]func(o *universe.Options, stateCh chan universe.Status) universe.Universe{
-		"base": func(o *universe.Options, stateCh chan universe.Status) universe.Universe {
-			return universe.NewBaseUniverse(o, stateCh)
+	engines = map[string]func(o *universe.Options, stateCh chan universe.Status) (universe.Universe, error){
+		"base": func(o *universe.Options, stateCh chan universe.Status) (universe.Universe, error) {
+			return universe.NewBaseUniverse(o, stateCh), nil
 		},
-		"simple":        universe.NewSimpleUniverse,
-		"smallBuff":     universe.NewSmallBuffUniverse,
-		"multithreaded": universe.NewMultithreadedUniverse,
+		"simple":        bounded(universe.NewSimpleUniverse),
+		"smallBuff":     bounded(universe.NewSmallBuffUniverse),
+		"multithreaded": bounded(universe.NewMultithreadedUniverse),
+		"bitboard":      bounded(universe.NewBitboardUniverse),
+		"hashlife":      universe.NewHashLifeUniverse,
+		"sparse":        universe.NewSparseUniverse,
+		"activeTiles":   bounded(universe.NewActiveTilesUniverse),
 	}
 )
 
+//exit codes of the "run" mode, allow the scripts to distinguish why the simulation is finished
+const (
+	ExitStabilized = 0 //the universe reached the still life, oscillator or extinct state
+	ExitMaxSteps   = 3 //the steps limit is reached
+	ExitOverloaded = 4 //the engine is too slow for the simulation interval
+)
+
+//placement is the template placed on the field with the transforms
+type placement struct {
+	template  universe.Template
+	placement universe.Placement
+}
+
 type EnvOptions struct {
 	interactive bool
 	randomData  bool
 	engine      string
+	rule        string
+	topology    string
+	file        string
+	pattern     string
+	at          string
+	template    *universe.Template //the template loaded from the pattern file
+	places      []string
+	placements  []placement //the templates placed with the transforms
+	stepExp     int
+	nodeCache   int
+	workers     int
+	region      string
+	symmetry    string
+	resume      string
+	snapshot    []byte //the snapshot data to resume the simulation
+	snapshotUI  string //the snapshot file of the console UI
+	render      string //the render mode of the console UI
+	renderMode  view.RenderMode
+	censusFile  string //the file to write the census of the final state
+	escapes     bool   //report the escaping spaceships
+	history     int    //the undo history size, negative for the default depending on the field size
 }
 
 func main() {
@@ -44,7 +87,10 @@ func main() {
 		stateCh = make(chan universe.Status, 10) //the buffered channel to getting the universe status
 	}
 
-	u := engines[eo.engine](uo, stateCh)
+	u, err := engines[eo.engine](uo, stateCh)
+	if err != nil {
+		exitWithError(err)
+	}
 
 	u.AddTemplate(
 		universe.Template{
@@ -52,25 +98,53 @@ func main() {
 			Descr:       "the test sample with 3 stable patterns",
 			Coordinates: testSample,
 		})
+	for _, e := range pattern.Catalog() {
+		if tmpl, err := e.Template(); err == nil {
+			u.AddTemplate(tmpl)
+		}
+	}
 
-	if eo.randomData {
-		u.SettleWithRandomData()
+	if eo.snapshot != nil {
+		if err := u.LoadSnapshot(bytes.NewReader(eo.snapshot)); err != nil {
+			exitWithError(err)
+		}
+	} else if eo.randomData {
+		if err := u.SettleWithRandomData(); err != nil {
+			exitWithError(err)
+		}
+	} else if eo.template != nil || len(eo.placements) > 0 {
+		if eo.template != nil {
+			u.AddTemplate(*eo.template)
+			u.SettleTemplate(eo.template.Name)
+		}
+		for _, pl := range eo.placements {
+			u.AddTemplate(pl.template)
+			if err := u.SettleTemplateAt(pl.template.Name, pl.placement); err != nil {
+				exitWithError(err)
+			}
+		}
 	} else {
 		u.SettleTemplate("testSample1")
 	}
 
 	if eo.interactive {
 		v := view.NewConsoleUI()
+		v.SetSnapshotFile(eo.snapshotUI)
+		v.SetRenderMode(eo.renderMode)
+		v.SetEscapes(eo.escapes)
 		u.RegisterViewer(v)
 		v.Start()
 		u.Close()
 	} else {
 		v := view.NewConsoleOut()
+		v.SetCensusFile(eo.censusFile)
+		v.SetEscapes(eo.escapes)
 		u.RegisterViewer(v)
 		v.Start()
 		u.Run()
+		var st universe.Status
 		for {
-			st := <-stateCh
+			st = <-stateCh
 			if st.RunningMode == universe.RunningStateFinished {
 				break
 			}
@@ -78,7 +152,8 @@ func main() {
 		u.Close()
 		close(stateCh)
 		//waiting for all final output printing
-		time.Sleep(time.Millisecond * 200)
+		v.Wait()
+		os.Exit(exitCode(st))
 	}
 
 }
@@ -90,36 +165,202 @@ func initOptions() (eo *EnvOptions, uo *universe.Options) {
 	for k := range engines {
 		engineNames = append(engineNames, k)
 	}
-	eo = &EnvOptions{engine: "base"}
+	eo = &EnvOptions{
+		engine:     "base",
+		topology:   uo.Topology.String(),
+		stepExp:    universe.DefHashLifeStepExponent,
+		nodeCache:  universe.DefHashLifeCacheLimit,
+		snapshotUI: view.DefSnapshotFile,
+		render:     view.RenderBlock.String(),
+		history:    -1,
+	}
 	flaggy.DefaultParser.ShowHelpOnUnexpected = true
 
 	runMode := flaggy.NewSubcommand("run")
-	runMode.Description = "Run simulation with console output"
+	runMode.Description = fmt.Sprintf("Run simulation with console output (exit code: %v - stabilized, %v - max steps reached, %v - engine overloaded)",
+		ExitStabilized, ExitMaxSteps, ExitOverloaded)
+
+	runMode.String(&eo.resume, "", "resume", "Resume the simulation from the snapshot file (the field size, rule and topology are taken from the snapshot)")
+
+	runMode.String(&eo.censusFile, "", "census-json", "Write the census of the objects of the stabilized final state to the JSON file, \"-\" for the standard output")
+	runMode.Bool(&eo.escapes, "", "escapes", "Report the gliders and the spaceships escaping the reaction region (slows down the large fields)")
 
 	uiMode := flaggy.NewSubcommand("ui")
 	uiMode.Description = "Run with console UI"
+	uiMode.String(&eo.snapshotUI, "", "snapshot", "Snapshot file to save and load with the keybindings")
+	uiMode.Bool(&eo.escapes, "", "escapes", "Detect the gliders and the spaceships escaping the reaction region, can be switched by the keybinding (slows down the UI on the large fields)")
+	uiMode.String(&eo.render, "", "render", "Render mode of the field ["+strings.Join(view.RenderModeNames(), "|")+"], half and braille pack 2 and 8 cells to one char")
+
+	listMode := flaggy.NewSubcommand("list-patterns")
+	listMode.Description = "List the built-in patterns"
 
 	flaggy.AttachSubcommand(runMode, 1)
 	flaggy.AttachSubcommand(uiMode, 1)
+	flaggy.AttachSubcommand(listMode, 1)
 
 	flaggy.Int(&uo.Width, "x", "width", "Width of a simulation field")
 	flaggy.Int(&uo.Height, "y", "height", "Height of a simulation field")
 	flaggy.Duration(&uo.Interval, "i", "interval", "Simulation speed (interval between the steps) in format the number with 'ms' suffix, for example 150ms")
 	flaggy.Int(&uo.MaxSteps, "s", "maxSteps", "Limit the simulation to maxSteps")
+	flaggy.Int(&uo.CycleHistory, "", "cycle-history", "Number of the previous generations kept to detect oscillators, 0 disables the detection")
+	flaggy.Int(&eo.history, "", "history", fmt.Sprintf("ui: number of the previous states kept to undo, 0 disables the undo, every step compares the whole field with the previous state "+
+		"(default: %v, the undo is disabled on the fields larger than %v cells)", universe.DefHistory, universe.DefHistoryMaxCells))
 	flaggy.Bool(&eo.randomData, "r", "random", "Settle with random data")
+	flaggy.Int64(&uo.Soup.Seed, "", "seed", "Random data: the seed to reproduce the soup (default: a new seed, the seed is shown in the configuration)")
+	flaggy.Float64(&uo.Soup.Density, "", "density", fmt.Sprintf("Random data: the probability of the live cell in range (0, 1] (default: %v)", universe.DefSoupDensity))
+	flaggy.String(&eo.region, "", "region", "Random data: the region to fill in format x,y,width,height, for example 10,5,16,16 (default: the whole field)")
+	flaggy.String(&eo.symmetry, "", "symmetry", "Random data: the soup symmetry ["+strings.Join(universe.SymmetryNames(), "|")+"]")
 	flaggy.String(&eo.engine, "e", "engine", "Engine to use ["+strings.Join(engineNames, "|")+"]")
+	flaggy.String(&eo.rule, "", "rule", "Birth/survival rule in B/S notation, for example B3/S23, B36/S23, B2/S (default: the pattern file rule or "+universe.DefRule+")")
+	flaggy.String(&eo.topology, "t", "topology", "Field topology ["+strings.Join(universe.TopologyNames(), "|")+"]")
+
+	flaggy.String(&eo.file, "f", "file", "Settle with the pattern file [.rle|.lif|.cells]")
+	flaggy.String(&eo.pattern, "p", "pattern", "Settle with the built-in pattern, see list-patterns")
+	flaggy.String(&eo.at, "", "at", "Position of the pattern file in format x,y, for example 10,5")
+	flaggy.StringSlice(&eo.places, "", "place", "Place the built-in pattern or the pattern file, can be repeated, format name[:x:y][:center][:rot90|rot180|rot270][:fliph][:flipv], "+
+		"for example --place glider:5:5 --place glider:30:30:rot180 (center without x:y places the pattern to the field centre)")
+
+	flaggy.Int(&eo.stepExp, "", "step-exp", fmt.Sprintf("hashlife: advance the universe by 2^step-exp generations per step, step-exp in range 0..%d", universe.MaxHashLifeStepExponent))
+	flaggy.Int(&eo.nodeCache, "", "node-cache", "hashlife: number of the cached nodes triggering garbage collection, the limit is checked after each step, so the cache can grow beyond it during the step")
+	flaggy.Int(&eo.workers, "", "workers", "multithreaded: number of the workers (default: the number of CPUs)")
 
 	flaggy.Parse()
 
+	if listMode.Used {
+		listPatterns()
+		os.Exit(0)
+	}
 	eo.interactive = uiMode.Used
+	//the cells activity is shown by the console UI only
+	uo.TrackActivity = eo.interactive
 	if !uiMode.Used && !runMode.Used {
-		flaggy.ShowHelpAndExit("Specify the running mode \"run\" or \"ui\"")
+		flaggy.ShowHelpAndExit("Specify the running mode \"run\", \"ui\" or \"list-patterns\"")
 	}
 
 	_, ok := engines[eo.engine]
 	if !ok {
 		flaggy.ShowHelpAndExit("unknown engine")
 	}
+	if eo.resume != "" {
+		if eo.file != "" || eo.pattern != "" || len(eo.places) > 0 || eo.randomData {
+			flaggy.ShowHelpAndExit("resume can't be combined with the pattern or the random data")
+		}
+		data, err := ioutil.ReadFile(eo.resume)
+		if err != nil {
+			exitWithError(err)
+		}
+		s, err := universe.ReadSnapshot(bytes.NewReader(data))
+		if err != nil {
+			exitWithError(fmt.Errorf("%v: %v", eo.resume, err))
+		}
+		eo.snapshot = data
+		uo.Width, uo.Height = s.Options.Width, s.Options.Height
+		eo.rule = s.Options.Rule.String()
+		eo.topology = s.Options.Topology.String()
+	}
+	if uo.Width < 1 || uo.Height < 1 {
+		flaggy.ShowHelpAndExit("the field width and height must be positive")
+	}
+	switch {
+	case !eo.interactive:
+		//the undo is available in the console UI only, the history recording isn't needed
+		uo.History = 0
+	case eo.history >= 0:
+		uo.History = eo.history
+	case uo.Width*uo.Height > universe.DefHistoryMaxCells:
+		//every step compares the whole field with the previous state, the undo of the large fields is opt-in
+		uo.History = 0
+	}
+
+	var p *pattern.Pattern
+	if eo.file != "" {
+		pt, err := pattern.Load(eo.file)
+		if err != nil {
+			exitWithError(err)
+		}
+		p = &pt
+	}
+	if eo.pattern != "" {
+		if p != nil {
+			flaggy.ShowHelpAndExit("the pattern file and the built-in pattern can't be combined")
+		}
+		pt, err := pattern.Find(eo.pattern)
+		if err != nil {
+			flaggy.ShowHelpAndExit(err.Error())
+		}
+		p = &pt
+	}
+	if p != nil {
+		if eo.rule == "" && p.Rule != "" {
+			eo.rule = p.Rule
+		}
+	}
+	if eo.rule == "" {
+		eo.rule = universe.DefRule
+	}
+	if len(eo.places) > 0 && eo.randomData {
+		flaggy.ShowHelpAndExit("the placed patterns can't be combined with the random data")
+	}
+	for _, spec := range eo.places {
+		pl, err := parsePlacement(spec, uo)
+		if err != nil {
+			flaggy.ShowHelpAndExit(err.Error())
+		}
+		eo.placements = append(eo.placements, pl)
+	}
+
+	rule, err := universe.ParseRule(eo.rule)
+	if err != nil {
+		flaggy.ShowHelpAndExit(err.Error())
+	}
+	uo.Rule = rule
+
+	topology, err := universe.ParseTopology(eo.topology)
+	if err != nil {
+		flaggy.ShowHelpAndExit(err.Error())
+	}
+	uo.Topology = topology
+
+	if eo.renderMode, err = view.ParseRenderMode(eo.render); err != nil {
+		flaggy.ShowHelpAndExit(err.Error())
+	}
+
+	if err := uo.Soup.Validate(); err != nil {
+		flaggy.ShowHelpAndExit(err.Error())
+	}
+	if eo.region != "" {
+		r, err := parseInts(eo.region, 4)
+		if err != nil || r[2] < 1 || r[3] < 1 {
+			flaggy.ShowHelpAndExit(fmt.Sprintf("invalid region %q, expected x,y,width,height with positive width and height", eo.region))
+		}
+		uo.Soup.X, uo.Soup.Y, uo.Soup.Width, uo.Soup.Height = r[0], r[1], r[2], r[3]
+	}
+	if eo.symmetry != "" {
+		if uo.Soup.Symmetry, err = universe.ParseSymmetry(eo.symmetry); err != nil {
+			flaggy.ShowHelpAndExit(err.Error())
+		}
+	}
+
+	if eo.engine == "hashlife" {
+		if eo.stepExp < 0 || eo.stepExp > universe.MaxHashLifeStepExponent {
+			flaggy.ShowHelpAndExit(fmt.Sprintf("step-exp must be in range 0..%d", universe.MaxHashLifeStepExponent))
+		}
+		uo.SetAdvanced(map[string]interface{}{
+			universe.AdvStepExponent:  eo.stepExp,
+			universe.AdvNodeCacheSize: eo.nodeCache,
+		})
+	}
+	if eo.engine == "multithreaded" && eo.workers > 0 {
+		uo.SetAdvanced(map[string]interface{}{
+			universe.AdvWorkers: eo.workers,
+		})
+	}
+
+	if p != nil {
+		if eo.template, err = patternTemplate(p, eo.at, uo); err != nil {
+			exitWithError(err)
+		}
+	}
 
 	if eo.engine == "multithreaded" && uo.Interval != 0 {
 		fmt.Println("\nTo use multi-threading effectively set \"interval\" value to 0")
@@ -127,3 +368,129 @@ func initOptions() (eo *EnvOptions, uo *universe.Options) {
 
 	return
 }
+
+//bounded adapts the constructor of the engine accepting any options to the engines map
+func bounded(newUniverse func(o *universe.Options, stateCh chan universe.Status) universe.Universe) func(o *universe.Options, stateCh chan universe.Status) (universe.Universe, error) {
+	return func(o *universe.Options, stateCh chan universe.Status) (universe.Universe, error) {
+		return newUniverse(o, stateCh), nil
+	}
+}
+
+//listPatterns prints the built-in patterns catalog
+func listPatterns() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tCATEGORY\tSIZE\tPERIOD\tDESCRIPTION")
+	for _, e := range pattern.Catalog() {
+		p, err := e.Pattern()
+		if err != nil {
+			continue
+		}
+		period := "-"
+		if e.Period > 0 {
+			period = strconv.Itoa(e.Period)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%dx%d\t%s\t%s\n", e.Name, e.Category, p.Width, p.Height, period, e.Descr)
+	}
+	_ = w.Flush()
+}
+
+//patternTemplate creates the template from the pattern placed at the position "x,y"
+//returns the error if the pattern doesn't fit the field
+func patternTemplate(p *pattern.Pattern, at string, uo *universe.Options) (*universe.Template, error) {
+	ax, ay := 0, 0
+	if at != "" {
+		xy, err := parseInts(at, 2)
+		if err != nil || xy[0] < 0 || xy[1] < 0 {
+			return nil, fmt.Errorf("invalid pattern position %q, expected non negative x,y", at)
+		}
+		ax, ay = xy[0], xy[1]
+	}
+	if ax+p.Width > uo.Width || ay+p.Height > uo.Height {
+		return nil, fmt.Errorf("the pattern %q (%v x %v) placed at %v,%v doesn't fit the field %v x %v",
+			p.Name, p.Width, p.Height, ax, ay, uo.Width, uo.Height)
+	}
+	tmpl := p.Template()
+	tmpl.Coordinates = make([][]int, 0, len(p.Cells))
+	for _, c := range p.Cells {
+		tmpl.Coordinates = append(tmpl.Coordinates, []int{c[0] + ax, c[1] + ay})
+	}
+	return &tmpl, nil
+}
+
+//parsePlacement parses the placement of the pattern in format name[:x:y][:center][:rot90|rot180|rot270][:fliph][:flipv]
+//the name is the built-in pattern name or the pattern file
+func parsePlacement(spec string, uo *universe.Options) (pl placement, err error) {
+	fields := strings.Split(spec, ":")
+	p, err := pattern.Find(fields[0])
+	if err != nil {
+		if p, err = pattern.Load(fields[0]); err != nil {
+			return pl, fmt.Errorf("invalid placement %q: %v", spec, err)
+		}
+	}
+	pl.template = p.Template()
+	hasPosition := false
+	for i := 1; i < len(fields); i++ {
+		f := fields[i]
+		switch f {
+		case "center":
+			pl.placement.Center = true
+		case "rot90", "rot180", "rot270":
+			pl.placement.Rotation, _ = strconv.Atoi(strings.TrimPrefix(f, "rot"))
+		case "fliph":
+			pl.placement.FlipH = true
+		case "flipv":
+			pl.placement.FlipV = true
+		default:
+			//the position is two integer fields x:y, the comma separates the flag values
+			if i+1 < len(fields) && !hasPosition {
+				x, errX := strconv.Atoi(f)
+				y, errY := strconv.Atoi(fields[i+1])
+				if errX == nil && errY == nil {
+					pl.placement.X, pl.placement.Y = x, y
+					hasPosition = true
+					i++
+					continue
+				}
+			}
+			return pl, fmt.Errorf("invalid placement %q: unknown option %q", spec, f)
+		}
+	}
+	if pl.placement.Center && !hasPosition {
+		pl.placement.X, pl.placement.Y = uo.Width/2, uo.Height/2
+	}
+	return pl, nil
+}
+
+//parseInts parses n comma separated integers
+func parseInts(s string, n int) ([]int, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != n {
+		return nil, fmt.Errorf("expected %d comma separated integers", n)
+	}
+	values := make([]int, n)
+	for i, f := range fields {
+		v, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+//exitCode returns the exit code of the "run" mode by the finish reason
+func exitCode(st universe.Status) int {
+	switch st.FinishReason {
+	case universe.FinishReasonMaxSteps:
+		return ExitMaxSteps
+	case universe.FinishReasonSkippedTicks:
+		return ExitOverloaded
+	}
+	return ExitStabilized
+}
+
+//exitWithError prints the error and exits
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,103 @@
+package pattern
+
+import (
+	"fmt"
+	"simlife/src/universe"
+	"strings"
+)
+
+/*
+	The built-in catalog of the well-known patterns
+	the patterns are kept in the RLE format and parsed on demand
+	https://conwaylife.com/wiki/
+*/
+
+//pattern categories
+const (
+	CategoryStillLife  = "still life"
+	CategoryOscillator = "oscillator"
+	CategorySpaceship  = "spaceship"
+	CategoryGun        = "gun"
+	CategoryMethuselah = "methuselah"
+)
+
+//CatalogEntry is the pattern of the built-in catalog
+type CatalogEntry struct {
+	Name     string //the unique name used to select the pattern
+	Category string
+	Descr    string
+	Period   int    //the period of the oscillator, the spaceship or the gun, 0 otherwise
+	RLE      string //the pattern cells in the RLE format
+}
+
+var catalog = []CatalogEntry{
+	{"block", CategoryStillLife, "the smallest still life", 0, "x = 2, y = 2\n2o$2o!"},
+	{"beehive", CategoryStillLife, "the most common 6-cell still life", 0, "x = 4, y = 3\nb2o$o2bo$b2o!"},
+	{"loaf", CategoryStillLife, "the most common 7-cell still life", 0, "x = 4, y = 4\nb2o$o2bo$bobo$2bo!"},
+	{"boat", CategoryStillLife, "the only 5-cell still life", 0, "x = 3, y = 3\n2o$obo$bo!"},
+	{"tub", CategoryStillLife, "4-cell still life", 0, "x = 3, y = 3\nbo$obo$bo!"},
+
+	{"blinker", CategoryOscillator, "the smallest and the most common oscillator", 2, "x = 3, y = 1\n3o!"},
+	{"toad", CategoryOscillator, "period 2 oscillator", 2, "x = 4, y = 2\nb3o$3o!"},
+	{"beacon", CategoryOscillator, "period 2 oscillator made of two blocks", 2, "x = 4, y = 4\n2o$2o$2b2o$2b2o!"},
+	{"pulsar", CategoryOscillator, "the most common period 3 oscillator", 3,
+		"x = 13, y = 13\n2b3o3b3o2$o4bobo4bo$o4bobo4bo$o4bobo4bo$2b3o3b3o2$2b3o3b3o$o4bobo4bo$o4bobo4bo$o4bobo4bo2$2b3o3b3o!"},
+	{"pentadecathlon", CategoryOscillator, "period 15 oscillator", 15, "x = 10, y = 3\n2bo4bo$2ob4ob2o$2bo4bo!"},
+
+	{"glider", CategorySpaceship, "the smallest spaceship, moves diagonally by one cell every 4 generations", 4, "x = 3, y = 3\nbo$2bo$3o!"},
+	{"lwss", CategorySpaceship, "lightweight spaceship, moves orthogonally by 2 cells every 4 generations", 4, "x = 5, y = 4\nbo2bo$o$o3bo$4o!"},
+	{"mwss", CategorySpaceship, "middleweight spaceship", 4, "x = 6, y = 5\n3bo$bo3bo$o$o4bo$5o!"},
+	{"hwss", CategorySpaceship, "heavyweight spaceship", 4, "x = 7, y = 5\n3b2o$bo4bo$o$o5bo$6o!"},
+
+	{"gosper-gun", CategoryGun, "Gosper glider gun, the first known gun, emits a glider every 30 generations", 30,
+		"x = 36, y = 9\n24bo$22bobo$12b2o6b2o12b2o$11bo3bo4b2o12b2o$2o8bo5bo3b2o$2o8bo3bob2o4bobo$10bo5bo7bo$11bo3bo$12b2o!"},
+
+	{"r-pentomino", CategoryMethuselah, "stabilizes after 1103 generations", 0, "x = 3, y = 3\nb2o$2o$bo!"},
+	{"diehard", CategoryMethuselah, "disappears after 130 generations", 0, "x = 8, y = 3\n6bo$2o$bo3b3o!"},
+	{"acorn", CategoryMethuselah, "stabilizes after 5206 generations", 0, "x = 7, y = 3\nbo$3bo$2o2b3o!"},
+	{"b-heptomino", CategoryMethuselah, "stabilizes after 148 generations", 0, "x = 4, y = 3\nob2o$3o$bo!"},
+	{"pi-heptomino", CategoryMethuselah, "stabilizes after 173 generations", 0, "x = 3, y = 3\n3o$obo$obo!"},
+}
+
+//Catalog returns the built-in catalog entries
+func Catalog() []CatalogEntry {
+	entries := make([]CatalogEntry, len(catalog))
+	copy(entries, catalog)
+	return entries
+}
+
+//Find returns the pattern of the built-in catalog by its name
+func Find(name string) (Pattern, error) {
+	for _, e := range catalog {
+		if strings.EqualFold(e.Name, strings.TrimSpace(name)) {
+			return e.Pattern()
+		}
+	}
+	names := make([]string, 0, len(catalog))
+	for _, e := range catalog {
+		names = append(names, e.Name)
+	}
+	return Pattern{}, fmt.Errorf("unknown pattern %q, expected one of [%s]", name, strings.Join(names, "|"))
+}
+
+//Pattern parses the catalog entry to the pattern
+func (e CatalogEntry) Pattern() (Pattern, error) {
+	p, err := ReadRLE(strings.NewReader(e.RLE))
+	if err != nil {
+		return p, fmt.Errorf("%s: %v", e.Name, err)
+	}
+	p.Name = e.Name
+	p.Comments = append([]string{e.Descr}, p.Comments...)
+	return p, nil
+}
+
+//Template parses the catalog entry to the universe seeding template
+func (e CatalogEntry) Template() (universe.Template, error) {
+	p, err := e.Pattern()
+	if err != nil {
+		return universe.Template{}, err
+	}
+	tmpl := p.Template()
+	tmpl.Category = e.Category
+	return tmpl, nil
+}
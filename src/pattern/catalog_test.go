@@ -0,0 +1,87 @@
+package pattern
+
+import (
+	"reflect"
+	"simlife/src/universe"
+	"testing"
+)
+
+const catalogTestMargin = 20 //the free space around the pattern, so the spaceships don't reach the field edges
+
+func Test_Catalog(t *testing.T) {
+	names := map[string]bool{}
+	for _, e := range Catalog() {
+		if names[e.Name] {
+			t.Fatalf("%s: the name is not unique", e.Name)
+		}
+		names[e.Name] = true
+		p, err := Find(e.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p.Cells) == 0 || p.Name != e.Name || p.Comments[0] != e.Descr {
+			t.Fatalf("%s: invalid pattern %+v", e.Name, p)
+		}
+		if e.Category == CategoryStillLife || e.Period > 0 {
+			checkPeriod(t, e, p)
+		}
+	}
+	if _, err := Find("unknown"); err == nil {
+		t.Fatal("the unknown pattern is found")
+	}
+}
+
+//checkPeriod checks that the pattern returns to its initial shape after the period generations, not earlier
+func checkPeriod(t *testing.T, e CatalogEntry, p Pattern) {
+	t.Helper()
+	period := e.Period
+	if period == 0 {
+		period = 1
+	}
+	o := universe.DefaultUniverseOptions
+	o.Interval = 0
+	o.MaxSteps = 0
+	o.CycleHistory = 0
+	o.History = 0
+	o.Width = p.Width + 2*catalogTestMargin
+	o.Height = p.Height + 2*catalogTestMargin
+	stateCh := make(chan universe.Status, 10)
+	u := universe.NewBaseUniverse(&o, stateCh)
+	defer u.Close()
+	tmpl, _ := e.Template()
+	cells := make([][]int, 0, len(tmpl.Coordinates))
+	for _, c := range tmpl.Coordinates {
+		cells = append(cells, []int{c[0] + catalogTestMargin, c[1] + catalogTestMargin})
+	}
+	u.Settle(cells)
+	initial := shape(u.Area())
+	for i := 1; i <= period; i++ {
+		u.Step()
+		for st := <-stateCh; st.RunningMode == universe.RunningStateStep; st = <-stateCh {
+		}
+		s := shape(u.Area())
+		if e.Category == CategoryGun {
+			//the gun is compared without the emitted gliders
+			s = shape(cropArea(u.Area(), catalogTestMargin, catalogTestMargin, p.Width, p.Height))
+		}
+		if same := reflect.DeepEqual(s, initial); same != (i == period) {
+			t.Fatalf("%s: the shape after %d generations is the same: %v, the period is %d", e.Name, i, same, period)
+		}
+	}
+}
+
+//shape returns the live cells of the area shifted so the bounding box starts at 0,0
+func shape(a universe.Area) [][]int {
+	p := FromArea(a)
+	p.normalize()
+	return p.sortedCells()
+}
+
+//cropArea returns the part of the area
+func cropArea(a universe.Area, x int, y int, width int, height int) universe.Area {
+	c := universe.Area{Width: width, Height: height}
+	for _, row := range a.Entities[y : y+height] {
+		c.Entities = append(c.Entities, row[x:x+width])
+	}
+	return c
+}
@@ -0,0 +1,94 @@
+package pattern
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+	Life 1.06 format support
+	https://conwaylife.com/wiki/Life_1.06
+	the format contains the list of the live cells coordinates, which can be negative
+	the coordinates are shifted so the pattern bounding box starts at 0,0
+*/
+
+const (
+	life106Header        = "#Life 1.06"
+	life106MaxCoordinate = 1 << 30 //the maximum absolute value of the coordinates, so the pattern size doesn't overflow
+)
+
+//ReadLife106 reads the pattern in the Life 1.06 format
+func ReadLife106(r io.Reader) (p Pattern, err error) {
+	s := bufio.NewScanner(r)
+	lineNum := 0
+	for s.Scan() {
+		lineNum++
+		line := strings.TrimSpace(s.Text())
+		if lineNum == 1 {
+			if !strings.HasPrefix(line, life106Header) {
+				return p, fmt.Errorf("life 1.06: the header %q is not found", life106Header)
+			}
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			p.parseRLEComment(line)
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return p, fmt.Errorf("life 1.06: line %d: expected the cell coordinates \"x y\"", lineNum)
+		}
+		x, errX := strconv.Atoi(fields[0])
+		y, errY := strconv.Atoi(fields[1])
+		if errX != nil || errY != nil {
+			return p, fmt.Errorf("life 1.06: line %d: invalid cell coordinates %q", lineNum, line)
+		}
+		if x < -life106MaxCoordinate || x > life106MaxCoordinate || y < -life106MaxCoordinate || y > life106MaxCoordinate {
+			return p, fmt.Errorf("life 1.06: line %d: the cell coordinates %q are out of range", lineNum, line)
+		}
+		p.Cells = append(p.Cells, []int{x, y})
+	}
+	if err = s.Err(); err != nil {
+		return p, fmt.Errorf("life 1.06: %v", err)
+	}
+	if lineNum == 0 {
+		return p, fmt.Errorf("life 1.06: the header %q is not found", life106Header)
+	}
+	p.normalize()
+	return p, nil
+}
+
+//normalize shifts the cells so the bounding box starts at 0,0 and sets the pattern size
+func (p *Pattern) normalize() {
+	if len(p.Cells) == 0 {
+		p.Width, p.Height = 0, 0
+		return
+	}
+	minX, minY := p.Cells[0][0], p.Cells[0][1]
+	maxX, maxY := minX, minY
+	for _, c := range p.Cells {
+		if c[0] < minX {
+			minX = c[0]
+		}
+		if c[0] > maxX {
+			maxX = c[0]
+		}
+		if c[1] < minY {
+			minY = c[1]
+		}
+		if c[1] > maxY {
+			maxY = c[1]
+		}
+	}
+	for _, c := range p.Cells {
+		c[0] -= minX
+		c[1] -= minY
+	}
+	p.Width, p.Height = maxX-minX+1, maxY-minY+1
+}
@@ -0,0 +1,81 @@
+package pattern
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//Format represents the pattern file format
+type Format int
+
+const (
+	FormatUnknown Format = iota
+	FormatRLE
+	FormatLife106
+	FormatPlaintext
+)
+
+//Load loads the pattern from the file, the file format is detected by the file extension and the header
+//the pattern name is set to the file name if the file doesn't declare it
+func Load(filename string) (p Pattern, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return p, err
+	}
+	defer f.Close()
+	p, err = Read(f, filename)
+	if err != nil {
+		return p, fmt.Errorf("%s: %v", filename, err)
+	}
+	if p.Name == "" {
+		p.Name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	return p, nil
+}
+
+//Read reads the pattern, the format is detected by the file name extension and the header
+func Read(r io.Reader, filename string) (p Pattern, err error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(512)
+	switch DetectFormat(filename, head) {
+	case FormatRLE:
+		return ReadRLE(br)
+	case FormatLife106:
+		return ReadLife106(br)
+	case FormatPlaintext:
+		return ReadPlaintext(br)
+	}
+	return p, fmt.Errorf("unknown pattern format")
+}
+
+//DetectFormat detects the pattern format by the file name extension and the beginning of the file data
+func DetectFormat(filename string, head []byte) Format {
+	head = bytes.TrimSpace(head)
+	switch {
+	case bytes.HasPrefix(head, []byte("#Life 1.06")):
+		return FormatLife106
+	case bytes.HasPrefix(head, []byte("#Life")):
+		//other Life formats (1.05) are not supported
+		return FormatUnknown
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".rle":
+		return FormatRLE
+	case ".cells":
+		return FormatPlaintext
+	case ".lif", ".life":
+		return FormatLife106
+	}
+	switch {
+	case bytes.HasPrefix(head, []byte("!")), bytes.HasPrefix(head, []byte(".")), bytes.HasPrefix(head, []byte("O")):
+		return FormatPlaintext
+	case bytes.HasPrefix(head, []byte("#")), bytes.HasPrefix(head, []byte("x")):
+		return FormatRLE
+	}
+	return FormatUnknown
+}
@@ -0,0 +1,87 @@
+package pattern
+
+import (
+	"simlife/src/universe"
+	"sort"
+)
+
+/*
+	Pattern files support
+	the pattern is the set of live cells with the metadata (name, comments, rule) loaded from or saved to the pattern file
+*/
+
+//Pattern represents the pattern loaded from the pattern file
+type Pattern struct {
+	Name     string   //pattern name
+	Comments []string //comment lines
+	Width    int      //bounding box width
+	Height   int      //bounding box height
+	Rule     string   //rule declared by the pattern, empty if not declared
+	Cells    [][]int  //array of [x,y] coordinates of the live cells
+}
+
+//Template converts the pattern to the universe seeding template
+func (p Pattern) Template() universe.Template {
+	descr := ""
+	if len(p.Comments) > 0 {
+		descr = p.Comments[0]
+	}
+	return universe.Template{
+		Name:        p.Name,
+		Descr:       descr,
+		Coordinates: p.Cells,
+	}
+}
+
+//FromArea creates the pattern from the universe area
+//the pattern has the same size as the area, so the cells keep their positions
+func FromArea(a universe.Area) Pattern {
+	p := Pattern{Width: a.Width, Height: a.Height}
+	for y := range a.Entities {
+		for x, e := range a.Entities[y] {
+			if e {
+				p.Cells = append(p.Cells, []int{x, y})
+			}
+		}
+	}
+	return p
+}
+
+//bounds returns the size of the bounding box containing all pattern cells and the pattern declared size
+func (p Pattern) bounds() (width int, height int) {
+	width, height = p.Width, p.Height
+	for _, c := range p.Cells {
+		if c[0] >= width {
+			width = c[0] + 1
+		}
+		if c[1] >= height {
+			height = c[1] + 1
+		}
+	}
+	return
+}
+
+//sortedCells returns the unique cells with non negative coordinates sorted by rows
+func (p Pattern) sortedCells() [][]int {
+	cells := make([][]int, 0, len(p.Cells))
+	for _, c := range p.Cells {
+		if c[0] < 0 || c[1] < 0 {
+			continue
+		}
+		cells = append(cells, c)
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i][1] != cells[j][1] {
+			return cells[i][1] < cells[j][1]
+		}
+		return cells[i][0] < cells[j][0]
+	})
+	unique := cells[:0]
+	for i, c := range cells {
+		if i > 0 && c[0] == cells[i-1][0] && c[1] == cells[i-1][1] {
+			continue
+		}
+		unique = append(unique, c)
+	}
+	return unique
+}
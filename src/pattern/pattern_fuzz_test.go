@@ -0,0 +1,91 @@
+package pattern
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+/*
+	The fuzz tests of the pattern formats parsers
+	the parsers must not panic on any input, the parsed patterns must survive writing to RLE and reading back
+	run with: go test ./src/pattern -run none -fuzz Fuzz_ReadRLE
+*/
+
+func Fuzz_ReadRLE(f *testing.F) {
+	f.Add([]byte("#N Glider\n#C comment\nx = 3, y = 3, rule = B3/S23\nbob$2bo$3o!\n"))
+	f.Add([]byte("x = 2, y = 3\n$2o$\n2o!"))
+	f.Add([]byte("#r 23/3\nx = 0, y = 0\n!"))
+	f.Add([]byte("x = 10, y = 1\n3b2A3o!"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := ReadRLE(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		for _, c := range p.Cells {
+			if c[0] < 0 || c[1] < 0 || c[0] >= p.Width || c[1] >= p.Height {
+				t.Fatalf("the cell %v is outside the declared size %dx%d", c, p.Width, p.Height)
+			}
+		}
+		checkRoundTrip(t, p)
+	})
+}
+
+func Fuzz_ReadLife106(f *testing.F) {
+	f.Add([]byte("#Life 1.06\n#N Glider\n0 -1\n1 0\n-1 1\n0 1\n1 1\n"))
+	f.Add([]byte("#Life 1.06\n"))
+	f.Add([]byte("#Life 1.06\n-1073741824 1073741824\n1073741824 -1073741824\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := ReadLife106(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		for _, c := range p.Cells {
+			if c[0] < 0 || c[1] < 0 || c[0] >= p.Width || c[1] >= p.Height {
+				t.Fatalf("the cell %v is outside the pattern size %dx%d", c, p.Width, p.Height)
+			}
+		}
+		checkRoundTrip(t, p)
+	})
+}
+
+func Fuzz_ReadPlaintext(f *testing.F) {
+	f.Add([]byte("!Name: Glider\n!\n.O.\n..O\nOOO\n"))
+	f.Add([]byte("\n\n*..*\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := ReadPlaintext(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		checkRoundTrip(t, p)
+	})
+}
+
+func Fuzz_Read(f *testing.F) {
+	f.Add([]byte("x = 3, y = 1\n3o!"), "blinker.rle")
+	f.Add([]byte("#Life 1.06\n0 0\n1 0\n2 0\n"), "")
+	f.Add([]byte("OOO\n"), "blinker.cells")
+	f.Fuzz(func(t *testing.T, data []byte, filename string) {
+		_, _ = Read(bytes.NewReader(data), filename)
+	})
+}
+
+//checkRoundTrip writes the pattern to RLE, reads it back and compares the result with the pattern
+func checkRoundTrip(t *testing.T, p Pattern) {
+	t.Helper()
+	var b bytes.Buffer
+	if err := WriteRLE(&b, p); err != nil {
+		t.Fatalf("can't write the pattern: %v", err)
+	}
+	rle := b.String()
+	r, err := ReadRLE(&b)
+	if err != nil {
+		t.Fatalf("can't read the written pattern: %v\n%s", err, rle)
+	}
+	if r.Name != p.Name || r.Rule != p.Rule {
+		t.Fatalf("the name %q and the rule %q, expected %q and %q\n%s", r.Name, r.Rule, p.Name, p.Rule, rle)
+	}
+	if !reflect.DeepEqual(r.sortedCells(), p.sortedCells()) {
+		t.Fatalf("the cells %v, expected %v\n%s", r.sortedCells(), p.sortedCells(), rle)
+	}
+}
@@ -0,0 +1,52 @@
+package pattern
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+	Plaintext (.cells) format support
+	https://conwaylife.com/wiki/Plaintext
+	the lines started with '!' are the comments, '.' is the dead cell, 'O' is the live cell
+*/
+
+//ReadPlaintext reads the pattern in the plaintext format
+func ReadPlaintext(r io.Reader) (p Pattern, err error) {
+	s := bufio.NewScanner(r)
+	lineNum := 0
+	y := 0
+	for s.Scan() {
+		lineNum++
+		line := strings.TrimRight(s.Text(), " \t\r")
+		if strings.HasPrefix(line, "!") {
+			comment := strings.TrimSpace(line[1:])
+			if strings.HasPrefix(comment, "Name:") {
+				p.Name = strings.TrimSpace(comment[len("Name:"):])
+			} else if comment != "" {
+				p.Comments = append(p.Comments, comment)
+			}
+			continue
+		}
+		for x, c := range line {
+			switch c {
+			case 'O', '*':
+				p.Cells = append(p.Cells, []int{x, y})
+			case '.':
+			default:
+				return p, fmt.Errorf("plaintext: line %d: unexpected symbol %q", lineNum, c)
+			}
+		}
+		if len(line) > p.Width {
+			p.Width = len(line)
+		}
+		y++
+	}
+	if err = s.Err(); err != nil {
+		return p, fmt.Errorf("plaintext: %v", err)
+	}
+	p.Height = y
+	return p, nil
+}
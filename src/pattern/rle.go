@@ -0,0 +1,217 @@
+package pattern
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+	Run Length Encoded (RLE) format support
+	https://conwaylife.com/wiki/Run_Length_Encoded
+*/
+
+const (
+	rleMaxLineLength = 70      //the maximum line length of the RLE data
+	rleMaxRunCount   = 1 << 20 //the maximum run count, protects against malformed data
+	rleMaxCells      = 1 << 22 //the maximum number of the live cells, protects against malformed data
+)
+
+//ReadRLE reads the pattern in the RLE format
+func ReadRLE(r io.Reader) (p Pattern, err error) {
+	s := bufio.NewScanner(r)
+	headerParsed := false
+	finished := false
+	x, y := 0, 0
+	lineNum := 0
+	for s.Scan() && !finished {
+		lineNum++
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		if !headerParsed {
+			if strings.HasPrefix(line, "#") {
+				p.parseRLEComment(line)
+				continue
+			}
+			if err = p.parseRLEHeader(line); err != nil {
+				return p, fmt.Errorf("rle: line %d: %v", lineNum, err)
+			}
+			headerParsed = true
+			continue
+		}
+		count := 0
+		for _, c := range line {
+			switch {
+			case c >= '0' && c <= '9':
+				count = count*10 + int(c-'0')
+				if count > rleMaxRunCount {
+					return p, fmt.Errorf("rle: line %d: run count is too large", lineNum)
+				}
+				continue
+			case c == ' ' || c == '\t':
+				continue
+			}
+			if count == 0 {
+				count = 1
+			}
+			switch {
+			case c == 'b' || c == '.':
+				x += count
+			case c == 'o' || (c >= 'A' && c <= 'X'):
+				if x+count > p.Width || y >= p.Height {
+					return p, fmt.Errorf("rle: line %d: the cells are outside the declared size %dx%d", lineNum, p.Width, p.Height)
+				}
+				if len(p.Cells)+count > rleMaxCells {
+					return p, fmt.Errorf("rle: line %d: too many live cells", lineNum)
+				}
+				for i := 0; i < count; i++ {
+					p.Cells = append(p.Cells, []int{x, y})
+					x++
+				}
+			case c == '$':
+				y += count
+				x = 0
+			case c == '!':
+				finished = true
+			default:
+				return p, fmt.Errorf("rle: line %d: unexpected symbol %q", lineNum, c)
+			}
+			if finished {
+				break
+			}
+			count = 0
+		}
+	}
+	if err = s.Err(); err != nil {
+		return p, fmt.Errorf("rle: %v", err)
+	}
+	if !headerParsed {
+		return p, fmt.Errorf("rle: the header line is not found")
+	}
+	return p, nil
+}
+
+//WriteRLE writes the pattern in the RLE format
+func WriteRLE(w io.Writer, p Pattern) error {
+	bw := bufio.NewWriter(w)
+	if p.Name != "" {
+		_, _ = fmt.Fprintf(bw, "#N %s\n", p.Name)
+	}
+	for _, c := range p.Comments {
+		_, _ = fmt.Fprintf(bw, "#C %s\n", c)
+	}
+	width, height := p.bounds()
+	if p.Rule != "" {
+		_, _ = fmt.Fprintf(bw, "x = %d, y = %d, rule = %s\n", width, height, p.Rule)
+	} else {
+		_, _ = fmt.Fprintf(bw, "x = %d, y = %d\n", width, height)
+	}
+
+	//the cells are written row by row, the trailing dead cells of the rows are omitted
+	lw := rleLineWriter{w: bw}
+	cells := p.sortedCells()
+	x, y := 0, 0
+	for i := 0; i < len(cells); {
+		c := cells[i]
+		if c[1] > y {
+			lw.writeRun(c[1]-y, '$')
+			x, y = 0, c[1]
+		}
+		if c[0] > x {
+			lw.writeRun(c[0]-x, 'b')
+		}
+		run := 1
+		for i+run < len(cells) && cells[i+run][1] == y && cells[i+run][0] == c[0]+run {
+			run++
+		}
+		lw.writeRun(run, 'o')
+		x = c[0] + run
+		i += run
+	}
+	lw.writeRun(1, '!')
+	_, _ = bw.WriteString("\n")
+	return bw.Flush()
+}
+
+//parseRLEComment parses the comment line (#N, #C, #c, #O, #r lines)
+func (p *Pattern) parseRLEComment(line string) {
+	if len(line) < 2 {
+		return
+	}
+	value := strings.TrimSpace(line[2:])
+	switch line[1] {
+	case 'N':
+		p.Name = value
+	case 'C', 'c', 'O':
+		p.Comments = append(p.Comments, value)
+	case 'r':
+		p.Rule = value
+	}
+}
+
+//parseRLEHeader parses the header line "x = m, y = n, rule = abc"
+//the rule can contain commas (the bounded grid suffix "B3/S23:T100,100"), so the parts without "=" continue the rule
+func (p *Pattern) parseRLEHeader(line string) (err error) {
+	hasX, hasY := false, false
+	key := ""
+	for _, field := range strings.Split(line, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			if key != "rule" {
+				return fmt.Errorf("invalid header field %q", field)
+			}
+			p.Rule += "," + strings.TrimSpace(field)
+			continue
+		}
+		key = strings.TrimSpace(kv[0])
+		value := strings.TrimSpace(kv[1])
+		switch key {
+		case "x":
+			p.Width, err = strconv.Atoi(value)
+			hasX = true
+		case "y":
+			p.Height, err = strconv.Atoi(value)
+			hasY = true
+		case "rule":
+			p.Rule = value
+		}
+		if err != nil {
+			return fmt.Errorf("invalid header field %q", field)
+		}
+	}
+	if !hasX || !hasY {
+		return fmt.Errorf("invalid header %q, the pattern size x and y is required", line)
+	}
+	if p.Width < 0 || p.Height < 0 {
+		return fmt.Errorf("invalid header %q, the pattern size must be non negative", line)
+	}
+	return nil
+}
+
+//rleLineWriter writes the RLE data runs splitting the data to lines with limited length
+type rleLineWriter struct {
+	w       *bufio.Writer
+	lineLen int
+}
+
+//writeRun writes the run of the symbols, the long runs are splitted to the runs readable by ReadRLE
+func (lw *rleLineWriter) writeRun(count int, c byte) {
+	for count > rleMaxRunCount {
+		lw.writeRun(rleMaxRunCount, c)
+		count -= rleMaxRunCount
+	}
+	s := string(c)
+	if count > 1 {
+		s = strconv.Itoa(count) + s
+	}
+	if lw.lineLen+len(s) > rleMaxLineLength {
+		_, _ = lw.w.WriteString("\n")
+		lw.lineLen = 0
+	}
+	_, _ = lw.w.WriteString(s)
+	lw.lineLen += len(s)
+}
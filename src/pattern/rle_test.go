@@ -0,0 +1,160 @@
+package pattern
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_ReadRLE(t *testing.T) {
+	tests := []struct {
+		name string
+		rle  string
+		want Pattern
+	}{
+		{
+			name: "glider",
+			rle:  "#N Glider\n#C The smallest spaceship\nx = 3, y = 3, rule = B3/S23\nbob$2bo$3o!\n",
+			want: Pattern{Name: "Glider", Comments: []string{"The smallest spaceship"}, Width: 3, Height: 3, Rule: "B3/S23",
+				Cells: [][]int{{1, 0}, {2, 1}, {0, 2}, {1, 2}, {2, 2}}},
+		},
+		{
+			name: "leading blank rows",
+			rle:  "x = 2, y = 3\n2$bo!",
+			want: Pattern{Width: 2, Height: 3, Cells: [][]int{{1, 2}}},
+		},
+		{
+			name: "trailing blank rows",
+			rle:  "x = 1, y = 4\no3$!",
+			want: Pattern{Width: 1, Height: 4, Cells: [][]int{{0, 0}}},
+		},
+		{
+			name: "multi-line runs",
+			rle:  "x = 6, y = 2\n2o\n3o$\nb\n5o\n!",
+			want: Pattern{Width: 6, Height: 2, Cells: [][]int{{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}, {1, 1}, {2, 1}, {3, 1}, {4, 1}, {5, 1}}},
+		},
+		{
+			name: "rule with commas",
+			rle:  "x = 1, y = 1, rule = B3/S23:T100,100\no!",
+			want: Pattern{Width: 1, Height: 1, Rule: "B3/S23:T100,100", Cells: [][]int{{0, 0}}},
+		},
+		{
+			name: "rule in comment",
+			rle:  "#r 23/3\nx = 0, y = 0\n!",
+			want: Pattern{Rule: "23/3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ReadRLE(strings.NewReader(tt.rle))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(p, tt.want) {
+				t.Fatalf("%+v, expected %+v", p, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ReadRLEErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rle  string
+	}{
+		{"no header", "#N Empty\n"},
+		{"no size", "rule = B3/S23\no!"},
+		{"invalid size", "x = a, y = 1\no!"},
+		{"negative size", "x = -1, y = 1\n!"},
+		{"comma outside rule", "x = 1, 2, y = 1\no!"},
+		{"outside size", "x = 2, y = 1\n3o!"},
+		{"unexpected symbol", "x = 2, y = 1\n2z!"},
+		{"run count too large", "x = 2, y = 1\n99999999o!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if p, err := ReadRLE(strings.NewReader(tt.rle)); err == nil {
+				t.Fatalf("no error, the pattern %+v", p)
+			}
+		})
+	}
+}
+
+func Test_WriteRLE(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Pattern
+		want string
+	}{
+		{
+			name: "glider",
+			p: Pattern{Name: "Glider", Comments: []string{"The smallest spaceship"}, Width: 3, Height: 3, Rule: "B3/S23",
+				Cells: [][]int{{1, 0}, {2, 1}, {0, 2}, {1, 2}, {2, 2}}},
+			want: "#N Glider\n#C The smallest spaceship\nx = 3, y = 3, rule = B3/S23\nbo$2bo$3o!\n",
+		},
+		{
+			name: "leading blank rows",
+			p:    Pattern{Cells: [][]int{{1, 2}}},
+			want: "x = 2, y = 3\n2$bo!\n",
+		},
+		{
+			name: "trailing blank rows",
+			p:    Pattern{Width: 3, Height: 4, Cells: [][]int{{0, 0}}},
+			want: "x = 3, y = 4\no!\n",
+		},
+		{
+			name: "blank rows between",
+			p:    Pattern{Cells: [][]int{{0, 0}, {2, 3}, {1, 3}}},
+			want: "x = 3, y = 4\no3$b2o!\n",
+		},
+		{
+			name: "unsorted and duplicated cells",
+			p:    Pattern{Cells: [][]int{{2, 0}, {0, 0}, {1, 0}, {0, 0}}},
+			want: "x = 3, y = 1\n3o!\n",
+		},
+		{
+			name: "rule with commas",
+			p:    Pattern{Rule: "B3/S23:T100,100", Cells: [][]int{{0, 0}}},
+			want: "x = 1, y = 1, rule = B3/S23:T100,100\no!\n",
+		},
+		{
+			name: "long line",
+			p:    Pattern{Cells: checkerRow(40)},
+			want: "x = 79, y = 1\n" + strings.Repeat("ob", 35) + "\n" + strings.Repeat("ob", 4) + "o!\n",
+		},
+		{
+			name: "empty",
+			p:    Pattern{},
+			want: "x = 0, y = 0\n!\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := WriteRLE(&b, tt.p); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Fatalf("%q, expected %q", b.String(), tt.want)
+			}
+			//the written pattern is read back to the same cells
+			r, err := ReadRLE(&b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Name != tt.p.Name || r.Rule != tt.p.Rule || !reflect.DeepEqual(r.sortedCells(), tt.p.sortedCells()) {
+				t.Fatalf("read back %+v, expected %+v", r, tt.p)
+			}
+		})
+	}
+}
+
+//checkerRow returns n live cells in the row 0 separated by the dead cells
+func checkerRow(n int) [][]int {
+	cells := make([][]int, n)
+	for i := range cells {
+		cells[i] = []int{2 * i, 0}
+	}
+	return cells
+}
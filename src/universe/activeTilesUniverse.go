@@ -0,0 +1,162 @@
+package universe
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+	Universe implementation with the active region tracking
+	the field is splitted into the tiles, only the tiles changed in the previous generation
+	and their neighbours are recalculated, the cells of the other tiles keep their states
+	since their neighbourhoods didn't change
+	with non-bounded topologies the edge tiles are the neighbours of each other
+*/
+
+const DefActiveTileSize = 32 //default tile width and height
+
+type ActiveTilesUniverse struct {
+	*BaseUniverse
+	tmpBuff   Area
+	tiles     []activeTile
+	tilesX    int //number of the tiles in the row
+	tilesY    int //number of the tiles in the column
+	active    int //number of the recalculated tiles in the last iteration
+	wrapEdges bool
+}
+
+//activeTile is the tile of the field with the state of the last calculation
+type activeTile struct {
+	x1, y1, x2, y2 int
+	liveCells      int
+	changed        bool //the tile was changed in the last iteration
+	recalc         bool //the tile should be recalculated in the current iteration
+}
+
+func NewActiveTilesUniverse(o *Options, stateCh chan Status) Universe {
+	au := ActiveTilesUniverse{BaseUniverse: NewBaseUniverse(o, stateCh)}
+	//redefine the nextIteration
+	au.BaseUniverse.nextIteration = au.nextIteration
+	au.tmpBuff = createArea(au.area.Width, au.area.Height)
+	tileSize := au.advancedInt(AdvTileSize, DefActiveTileSize)
+	if tileSize < 1 {
+		tileSize = DefActiveTileSize
+	}
+	au.tilesX = (au.area.Width + tileSize - 1) / tileSize
+	au.tilesY = (au.area.Height + tileSize - 1) / tileSize
+	for ty := 0; ty < au.tilesY; ty++ {
+		for tx := 0; tx < au.tilesX; tx++ {
+			t := activeTile{x1: tx * tileSize, y1: ty * tileSize, x2: (tx+1)*tileSize - 1, y2: (ty+1)*tileSize - 1}
+			if t.x2 >= au.area.Width {
+				t.x2 = au.area.Width - 1
+			}
+			if t.y2 >= au.area.Height {
+				t.y2 = au.area.Height - 1
+			}
+			au.tiles = append(au.tiles, t)
+		}
+	}
+	au.wrapEdges = au.options.Topology != TopologyBounded
+	au.options.Advanced["engine"] = "activeTiles"
+	au.options.Advanced[AdvTileSize] = tileSize
+	au.options.Advanced["Tiles"] = len(au.tiles)
+	return &au
+}
+
+//nextIteration recalculates the active tiles and their neighbours
+func (au *ActiveTilesUniverse) nextIteration() (hasLiveEnitities bool, changed bool) {
+	au.area.Lock()
+	defer au.area.Unlock()
+	start := time.Now()
+	all := au.area.modified
+	au.area.modified = false
+	au.markTiles(all)
+
+	au.active = 0
+	liveCells := 0
+	for i := range au.tiles {
+		t := &au.tiles[i]
+		if t.recalc {
+			au.calcTile(t)
+			au.active++
+		} else {
+			t.changed = false
+		}
+		liveCells += t.liveCells
+		changed = changed || t.changed
+	}
+	for i := range au.tiles {
+		t := &au.tiles[i]
+		if t.changed {
+			for y := t.y1; y <= t.y2; y++ {
+				copy(au.area.Entities[y][t.x1:t.x2+1], au.tmpBuff.Entities[y][t.x1:t.x2+1])
+			}
+		}
+	}
+
+	au.state.LiveCells = liveCells
+	au.state.IterationTime = time.Since(start)
+	au.state.Details = map[string]interface{}{
+		"Active tiles": fmt.Sprintf("%d of %d", au.active, len(au.tiles)),
+	}
+	hasLiveEnitities = liveCells > 0
+	return
+}
+
+//markTiles marks the tiles to recalculate: the tiles changed in the previous iteration and their neighbours
+func (au *ActiveTilesUniverse) markTiles(all bool) {
+	edgeChanged := false
+	for i := range au.tiles {
+		au.tiles[i].recalc = all
+		if au.tiles[i].changed && au.isEdgeTile(i) {
+			edgeChanged = true
+		}
+	}
+	if all {
+		return
+	}
+	for i := range au.tiles {
+		if !au.tiles[i].changed {
+			continue
+		}
+		tx, ty := i%au.tilesX, i/au.tilesX
+		for dy := -1; dy < 2; dy++ {
+			for dx := -1; dx < 2; dx++ {
+				nx, ny := tx+dx, ty+dy
+				if nx >= 0 && ny >= 0 && nx < au.tilesX && ny < au.tilesY {
+					au.tiles[ny*au.tilesX+nx].recalc = true
+				}
+			}
+		}
+	}
+	//the edge cells are the neighbours of the opposite edge cells
+	if au.wrapEdges && edgeChanged {
+		for i := range au.tiles {
+			if au.isEdgeTile(i) {
+				au.tiles[i].recalc = true
+			}
+		}
+	}
+}
+
+//isEdgeTile reports whether the tile i is at the field edge
+func (au *ActiveTilesUniverse) isEdgeTile(i int) bool {
+	tx, ty := i%au.tilesX, i/au.tilesX
+	return tx == 0 || ty == 0 || tx == au.tilesX-1 || ty == au.tilesY-1
+}
+
+//calcTile calculates the new states for the tile cells to the temporary buffer
+func (au *ActiveTilesUniverse) calcTile(t *activeTile) {
+	t.liveCells = 0
+	t.changed = false
+	for y := t.y1; y <= t.y2; y++ {
+		for x := t.x1; x <= t.x2; x++ {
+			nextState := au.cellNextState(x, y)
+			if nextState {
+				t.liveCells++
+			}
+			t.changed = t.changed || nextState != bool(au.area.Entities[y][x])
+			au.tmpBuff.Entities[y][x] = Cell(nextState)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package universe
+
+/*
+	The cells activity tracking
+	the activity is calculated by comparing the generation after the step with the previous one, so it works with any engine
+	the age is the number of the steps the cell is alive, the heat is the cumulative number of the births and deaths,
+	it shows the regions which are still churning
+	the activity is tracked in the window coordinates, the engines moving the window (sparse) report the window origin,
+	so the activity is shifted with the window, the cells moved into the window have no history and are counted as settled
+	the activity is double buffered, the next activity is calculated to the buffer of the previous but one,
+	the readers get the copy of the activity
+*/
+
+//CellActivity is the activity of one cell
+type CellActivity struct {
+	Age  int32 //the number of the steps the cell is alive, 0 for the dead cell, 1 for the newborn or settled cell
+	Heat int32 //the cumulative number of the births and deaths since the clear
+	Died bool  //the cell died in the last step
+}
+
+//Activity is the activity of the field cells
+type Activity struct {
+	Width   int
+	Height  int
+	Cells   [][]CellActivity
+	MaxHeat int32 //the maximum heat of the field cells
+	Births  int   //the number of the cells born in the last step
+	Deaths  int   //the number of the cells died in the last step
+	originX int   //the field coordinates of the window 0,0 cell
+	originY int
+}
+
+//newActivity creates the activity of the area with the window origin, the live cells are settled (age 1)
+func newActivity(a Area, originX int, originY int) *Activity {
+	act := createActivity(a.Width, a.Height)
+	act.originX, act.originY = originX, originY
+	for y, row := range a.Entities {
+		for x, e := range row {
+			if e {
+				act.Cells[y][x].Age = 1
+			}
+		}
+	}
+	return act
+}
+
+//next calculates the activity n of the area with the window origin after the step (step is true) or after the edit
+//the edit doesn't change the heat, the settled cells are not counted as the births
+//the activity n should have the same size as the area
+func (act *Activity) next(n *Activity, a Area, originX int, originY int, step bool) {
+	dx, dy := originX-act.originX, originY-act.originY
+	n.originX, n.originY = originX, originY
+	n.MaxHeat, n.Births, n.Deaths = 0, 0, 0
+	for y, row := range a.Entities {
+		cur := n.Cells[y]
+		py := y + dy
+		for x, e := range row {
+			var p CellActivity
+			px := x + dx
+			known := px >= 0 && py >= 0 && px < act.Width && py < act.Height
+			if known {
+				p = act.Cells[py][px]
+			}
+			c := CellActivity{Heat: p.Heat}
+			switch {
+			case bool(e) && p.Age > 0:
+				c.Age = p.Age
+				if step {
+					c.Age++
+				}
+			case bool(e):
+				c.Age = 1
+				if step && known {
+					c.Heat++
+					n.Births++
+				}
+			case p.Age > 0 && step:
+				c.Died = true
+				c.Heat++
+				n.Deaths++
+			}
+			if c.Heat > n.MaxHeat {
+				n.MaxHeat = c.Heat
+			}
+			cur[x] = c
+		}
+	}
+}
+
+//createActivity allocates the activity of the field
+func createActivity(width int, height int) *Activity {
+	act := &Activity{Width: width, Height: height, Cells: make([][]CellActivity, height)}
+	b := make([]CellActivity, width*height)
+	for i := range act.Cells {
+		start := width * i
+		act.Cells[i] = b[start : start+width : start+width]
+	}
+	return act
+}
+
+//Activity returns the copy of the activity of the field cells after the last step or edit, nil if the tracking is disabled
+//the activity buffers are reused by the next steps, so the caller gets the copy it can keep
+func (u *BaseUniverse) Activity() *Activity {
+	u.area.Lock()
+	defer u.area.Unlock()
+	if u.activity == nil {
+		return nil
+	}
+	return u.activity.copy()
+}
+
+//copy returns the deep copy of the activity
+func (act *Activity) copy() *Activity {
+	c := createActivity(act.Width, act.Height)
+	for y, row := range act.Cells {
+		copy(c.Cells[y], row)
+	}
+	c.MaxHeat, c.Births, c.Deaths = act.MaxHeat, act.Births, act.Deaths
+	c.originX, c.originY = act.originX, act.originY
+	return c
+}
+
+//trackActivity updates the activity after the step or the edit, should be called with the locked area
+func (u *BaseUniverse) trackActivity(step bool) {
+	if !u.options.TrackActivity {
+		return
+	}
+	originX, originY := 0, 0
+	if u.windowOrigin != nil {
+		originX, originY = u.windowOrigin()
+	}
+	act := u.activity
+	if act == nil || act.Width != u.area.Width || act.Height != u.area.Height {
+		u.activity = newActivity(u.area.Area, originX, originY)
+		return
+	}
+	n := u.spareActivity
+	if n == nil || n.Width != act.Width || n.Height != act.Height {
+		n = createActivity(act.Width, act.Height)
+	}
+	act.next(n, u.area.Area, originX, originY, step)
+	u.activity, u.spareActivity = n, act
+	if step {
+		u.state.Births, u.state.Deaths = u.activity.Births, u.activity.Deaths
+	}
+}
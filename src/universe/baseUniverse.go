@@ -1,7 +1,7 @@
 package universe
 
 import (
-	"math/rand"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -21,6 +21,12 @@ type Options struct {
 	Interval        time.Duration
 	MaxSteps        int
 	MaxSkippedTicks int
+	Rule            Rule                   //birth/survival rule, Conway's B3/S23 by default
+	Topology        Topology               //the way the field edges are glued together
+	CycleHistory    int                    //the number of the previous generations kept to detect cycles, 0 disables the detection
+	History         int                    //the number of the previous states kept to undo, 0 disables the undo, the recording compares the whole area every step
+	TrackActivity   bool                   //track the ages of the cells and the births and deaths, see Activity
+	Soup            Soup                   //the random soup configuration of SettleWithRandomData
 	Advanced        map[string]interface{} //advanced options (engine specific)
 }
 
@@ -30,6 +36,13 @@ type Status struct {
 	RunningMode   RunningState
 	LiveCells     int
 	IterationTime time.Duration
+	FinishReason  FinishReason           //the reason why the simulation is finished
+	Period        int                    //the period (in the generations) of the oscillator if the simulation is finished with the repeating generations
+	Err           error                  //the error if the simulation is finished abnormally
+	Undo          int                    //the number of the previous states available to undo
+	Redo          int                    //the number of the undone states available to redo
+	Births        int                    //the number of the cells born in the last step, the activity tracking only
+	Deaths        int                    //the number of the cells died in the last step, the activity tracking only
 	Details       map[string]interface{} //advanced details (engine specific)
 }
 
@@ -45,6 +58,7 @@ type Template struct {
 	Name        string  //template name
 	Descr       string  //template descr
 	Coordinates [][]int //array of [x,y] coordinates
+	Category    string  //template category, optional
 }
 
 //The universe running status at the concrete moment
@@ -57,6 +71,9 @@ const (
 	DefWidth              = 40
 	DefHeight             = 15
 	DefMaxSkippedTicks    = 5
+	DefCycleHistory       = 100
+	DefHistory            = 100
+	DefHistoryMaxCells    = 1 << 20 //the largest field with the undo history enabled by default, the recording compares the whole area every step
 )
 
 const (
@@ -72,6 +89,23 @@ var DefaultUniverseOptions = Options{
 	Interval:        DefSimulationInterval,
 	MaxSteps:        DefMaxSteps,
 	MaxSkippedTicks: DefMaxSkippedTicks,
+	Rule:            ConwayRule,
+	CycleHistory:    DefCycleHistory,
+	History:         DefHistory,
+	Soup:            Soup{Density: DefSoupDensity},
+}
+
+//SetAdvanced merges the values into the advanced options,
+//the map is copied, so the shared map (of the default options or read by the views) is not modified
+func (o *Options) SetAdvanced(values map[string]interface{}) {
+	advanced := make(map[string]interface{}, len(o.Advanced)+len(values))
+	for k, v := range o.Advanced {
+		advanced[k] = v
+	}
+	for k, v := range values {
+		advanced[k] = v
+	}
+	o.Advanced = advanced
 }
 
 //BaseUniverse is the base universe's engine
@@ -86,13 +120,38 @@ type BaseUniverse struct {
 	area struct {
 		Area
 		sync.Mutex
+		modified bool //the area was modified outside the nextIteration (settled, edited, cleared)
 	}
 	stateCh       chan Status
 	views         []Viewer
 	templates     map[string]Template
+	templateNames []string //the template names in the order of adding
 	controlCh     chan func()
 	closeCh       chan bool
 	nextIteration func() (hasLiveEnitities bool, changed bool)
+	onClear       func()        //optional, can be implemented by successor keeping the cells outside the area
+	stateHash     func() uint64 //calculates the hash of the current generation, can be implemented by successor
+	cycles        *cycleDetector
+	history       *history
+	activity      *Activity //the cells activity, nil if the tracking is disabled
+	spareActivity *Activity //the activity buffer for the next step
+	nextSeed      int64     //the seed of the next random soup
+
+	//optional, can be implemented by successor keeping the cells outside the area:
+	//fieldState returns the field state recorded to the undo history with the area,
+	//restoreField restores the recorded field state (nil is the initial empty field), returns the number of the live cells,
+	//windowOrigin returns the field coordinates of the area 0,0 cell if the engine moves the window,
+	//stepPeriod converts the period of the cycle from the steps to the generations if the engine advances several generations per step,
+	//fieldCells returns the window origin and the live cells outside the window saved to the snapshot,
+	//settleField moves the window and settles the cells outside it after the area is loaded, returns the number of the live cells
+	fieldState   func() interface{}
+	restoreField func(field interface{}) (liveCells int)
+	windowOrigin func() (x int, y int)
+	stepPeriod   func(steps int) (generations int)
+	fieldCells   func() (originX int, originY int, outside [][]int)
+	settleField  func(originX int, originY int, outside [][]int) (liveCells int)
+
+	optionsLock sync.Mutex //guards the options read by Options, the advanced options are replaced by the universe goroutine
 }
 
 //NewBaseUniverse creates the BaseUniverse instance
@@ -100,9 +159,9 @@ func NewBaseUniverse(o *Options, stateCh chan Status) *BaseUniverse {
 	if o == nil {
 		o = &DefaultUniverseOptions
 	}
-	o.Advanced = make(map[string]interface{})
-	o.Advanced["engine"] = "base"
-
+	if o.Rule.IsZero() {
+		o.Rule = ConwayRule
+	}
 	u := BaseUniverse{
 		options:   *o,
 		controlCh: make(chan func(), 1),
@@ -110,11 +169,29 @@ func NewBaseUniverse(o *Options, stateCh chan Status) *BaseUniverse {
 		stateCh:   stateCh,
 		templates: map[string]Template{},
 	}
+	//keep the advanced options passed by the caller, the engines read their settings from there
+	//the caller's map isn't modified, so the engine specific values don't leak to the other universes
+	u.options.SetAdvanced(map[string]interface{}{"engine": "base"})
 	//nextIteration can be implemented by successor
 	u.nextIteration = u._nextIteration
+	u.stateHash = u._stateHash
+	if o.CycleHistory > 0 {
+		u.cycles = newCycleDetector(o.CycleHistory)
+	}
 	u.state.Details = make(map[string]interface{})
 
 	u.area.Area = createArea(o.Width, o.Height)
+	u.area.modified = true
+	if o.History > 0 {
+		u.history = newHistory(o.History, u.area.Area)
+	}
+	if o.TrackActivity {
+		u.activity = newActivity(u.area.Area, 0, 0)
+	}
+	u.nextSeed = o.Soup.Seed
+	if u.nextSeed == 0 {
+		u.nextSeed = time.Now().UnixNano()
+	}
 	u.refreshView()
 	go u.mainLoop()
 	return &u
@@ -123,9 +200,21 @@ func NewBaseUniverse(o *Options, stateCh chan Status) *BaseUniverse {
 //AddTemplate adds the seeding template to the internal storage
 //the universe can be populated with this template by call SettleTemplate
 func (u *BaseUniverse) AddTemplate(tmpl Template) {
+	if _, ok := u.templates[tmpl.Name]; !ok {
+		u.templateNames = append(u.templateNames, tmpl.Name)
+	}
 	u.templates[tmpl.Name] = tmpl
 }
 
+//Templates returns the seeding templates in the order of adding
+func (u *BaseUniverse) Templates() []Template {
+	templates := make([]Template, 0, len(u.templateNames))
+	for _, name := range u.templateNames {
+		templates = append(templates, u.templates[name])
+	}
+	return templates
+}
+
 //Settle settles the universe with data
 //vc - array of x,y coordinates
 func (u *BaseUniverse) Settle(vc [][]int) {
@@ -148,29 +237,62 @@ func (u *BaseUniverse) SettleTemplate(name string) {
 	u.refreshView()
 }
 
-//SettleWithRandomData populates the universe with random data
-func (u *BaseUniverse) SettleWithRandomData() {
-	if u.state.RunningMode == RunningStateManual || u.state.RunningMode == RunningStateFinished {
-		u.controlCh <- u.clear
-		u.controlCh <- func() {
-			u.area.Lock()
-			for i := 0; i < u.area.Width*u.area.Height; i++ {
-				u.settle([][]int{{rand.Intn(u.area.Width), rand.Intn(u.area.Height)}}, Cell(true))
-			}
-			u.area.Unlock()
-			u.state.LiveCells = u.liveCells()
-			u.refreshView()
+//SettleTemplateAt populates the universe with the seeding template flipped, rotated and placed according to the placement
+//the cells outside the field are dropped, the error is returned in this case or if the template is not found
+func (u *BaseUniverse) SettleTemplateAt(name string, p Placement) error {
+	tmpl, ok := u.templates[name]
+	if !ok {
+		return fmt.Errorf("unknown template %q", name)
+	}
+	tmpl, err := tmpl.Transform(p)
+	if err != nil {
+		return err
+	}
+	u.area.Lock()
+	dropped := u.settle(tmpl.Coordinates, Cell(true))
+	u.area.Unlock()
+	u.state.LiveCells = u.liveCells()
+	u.refreshView()
+	if dropped > 0 {
+		return fmt.Errorf("%d cells of the template %q placed at %v,%v are outside the field", dropped, name, p.X, p.Y)
+	}
+	return nil
+}
+
+//SettleWithRandomData populates the universe with the random soup configured by Options.Soup
+//the soups are generated from the consecutive seeds starting with Options.Soup.Seed,
+//the seed of the soup is recorded to the advanced options, so the soup can be reproduced
+//returns the error if the soup configuration is invalid, the running simulation isn't seeded
+func (u *BaseUniverse) SettleWithRandomData() error {
+	if err := u.options.Soup.Validate(); err != nil {
+		return err
+	}
+	u.controlCh <- func() {
+		if mode := u.runningMode(); mode != RunningStateManual && mode != RunningStateFinished {
+			return
 		}
+		soup := u.options.Soup
+		soup.Seed = u.nextSeed
+		u.nextSeed++
+		u.setAdvanced(map[string]interface{}{"Seed": soup.Seed, "Soup": soup.String()})
+		u.clear()
+		u.area.Lock()
+		u.settle(soup.Cells(u.area.Width, u.area.Height), Cell(true))
+		u.area.Unlock()
+		u.state.LiveCells = u.liveCells()
+		u.refreshView()
 	}
+	return nil
 }
 
 //InverseCell inverses the cell state at point x, y
 func (u *BaseUniverse) InverseCell(x int, y int) {
-	if x >= u.area.Width || y >= u.area.Height {
+	if x < 0 || y < 0 || x >= u.area.Width || y >= u.area.Height {
 		return
 	}
 	u.area.Lock()
 	u.area.Entities[y][x] = !u.area.Entities[y][x]
+	u.areaModified(false)
 	u.area.Unlock()
 	u.refreshView()
 }
@@ -188,11 +310,13 @@ func (u *BaseUniverse) StateCh() chan Status {
 
 //Status returns current universe status represented by Status struct
 func (u *BaseUniverse) Status() Status {
-	return u.state.Status
+	return u.historyStatus(u.state.Status)
 }
 
 //Status returns current universe configuration represented by Options struct
 func (u *BaseUniverse) Options() Options {
+	u.optionsLock.Lock()
+	defer u.optionsLock.Unlock()
 	return u.options
 }
 
@@ -224,6 +348,32 @@ func (u *BaseUniverse) Clear() {
 	u.controlCh <- u.clear
 }
 
+//Undo restores the previous state of the universe (before the last step or edit), returns immediately
+//the running simulation is stopped, the Status struct will be written to the stateCh on finish
+func (u *BaseUniverse) Undo() {
+	u.Rewind(1)
+}
+
+//Redo reapplies the last undone state, returns immediately
+//the Status struct will be written to the stateCh on finish
+func (u *BaseUniverse) Redo() {
+	u.controlCh <- func() {
+		u.restore(func(a Area) (int, interface{}, bool) {
+			return u.history.forward(a, 1)
+		})
+	}
+}
+
+//Rewind restores the state n steps or edits back, returns immediately
+//the running simulation is stopped, the Status struct will be written to the stateCh on finish
+func (u *BaseUniverse) Rewind(n int) {
+	u.controlCh <- func() {
+		u.restore(func(a Area) (int, interface{}, bool) {
+			return u.history.back(a, n)
+		})
+	}
+}
+
 //Close stops the main loop, close the channels, returns immediately
 func (u *BaseUniverse) Close() {
 	u.closeCh <- true
@@ -245,14 +395,11 @@ func (u *BaseUniverse) mainLoop() {
 	close(u.controlCh)
 }
 
-//settle places the Cell at position x,y
-func (u *BaseUniverse) settle(vc [][]int, entity Cell) {
-	for _, v := range vc {
-		if v[0] >= u.area.Width || v[1] >= u.area.Height {
-			continue
-		}
-		u.area.Entities[v[1]][v[0]] = entity
-	}
+//settle places the Cell at position x,y, returns the number of the dropped cells outside the field
+func (u *BaseUniverse) settle(vc [][]int, entity Cell) (dropped int) {
+	dropped = u.place(vc, entity)
+	u.areaModified(false)
+	return
 }
 
 //liveCells calculates the count of live cells
@@ -273,13 +420,21 @@ func (u *BaseUniverse) liveCells() int {
 func (u *BaseUniverse) switchRunningState(to RunningState) {
 	u.state.Lock()
 	u.state.RunningMode = to
-	st := u.state.Status
+	st := u.historyStatus(u.state.Status)
 	u.state.Unlock()
 	if u.stateCh != nil {
 		u.stateCh <- st
 	}
 }
 
+//runningMode returns the current running state, the state is switched by the universe goroutine,
+//so the running cycle goroutine reads it under the lock
+func (u *BaseUniverse) runningMode() RunningState {
+	u.state.Lock()
+	defer u.state.Unlock()
+	return u.state.RunningMode
+}
+
 //run starts the universe simulation
 //simulation will stop on Stop() calling or when the boundary conditions are reached
 func (u *BaseUniverse) run() {
@@ -289,23 +444,32 @@ func (u *BaseUniverse) run() {
 		done := make(chan bool)
 		defer close(done)
 		for {
-			mode := u.state.RunningMode
+			mode := u.runningMode()
 			if mode != RunningStateRun && mode != RunningStateStep {
 				break
 			}
 			if skipped > u.options.MaxSkippedTicks {
-				u.switchRunningState(RunningStateFinished)
-				//todo write the warning message
+				u.state.Lock()
+				u.state.Err = ErrEngineOverloaded
+				u.state.Unlock()
+				u.finish(FinishReasonSkippedTicks, 0)
+				u.refreshView()
 				break
 			}
 			//skip the tick if the universe is still in the calculation mode
 			if mode != RunningStateStep {
-				skipped = 0
+				start := time.Now()
 				u.controlCh <- func() {
 					u.step()
 					done <- true
 				}
 				<-done
+				//the step took longer than the interval, the ticks during the calculation were skipped
+				if elapsed := time.Since(start); u.options.Interval > 0 && elapsed > u.options.Interval {
+					skipped += int(elapsed / u.options.Interval)
+				} else {
+					skipped = 0
+				}
 			} else {
 				skipped++
 			}
@@ -327,13 +491,14 @@ func (u *BaseUniverse) stop() {
 //step does the new one state calculation for entire universe
 func (u *BaseUniverse) step() {
 
-	finished := false
+	reason := FinishReasonNone
+	period := 0
 	rm := u.state.RunningMode
 	maxIter := u.options.MaxSteps
 	u.state.IterationNum++
 	defer func() {
-		if finished {
-			u.switchRunningState(RunningStateFinished)
+		if reason != FinishReasonNone {
+			u.finish(reason, period)
 		} else {
 			u.switchRunningState(rm)
 		}
@@ -341,16 +506,118 @@ func (u *BaseUniverse) step() {
 	}()
 
 	if maxIter != 0 && u.state.IterationNum >= maxIter {
-		finished = true
+		reason = FinishReasonMaxSteps
 		return
 	}
 	u.switchRunningState(RunningStateStep)
 	isAlive, changed := u.nextIteration()
-	if !isAlive || !changed {
-		finished = true
+	u.area.Lock()
+	u.recordHistory(false)
+	u.trackActivity(true)
+	u.area.Unlock()
+	switch {
+	case !isAlive:
+		reason = FinishReasonExtinct
+	case !changed:
+		reason, period = FinishReasonStillLife, 1
+		if u.stepPeriod != nil {
+			u.area.Lock()
+			if period = u.stepPeriod(1); period > 1 {
+				reason = FinishReasonOscillator
+			}
+			u.area.Unlock()
+		}
+	case u.cycles != nil:
+		u.area.Lock()
+		period = u.cycles.add(u.stateHash(), u.state.IterationNum)
+		if period > 0 && u.stepPeriod != nil {
+			period = u.stepPeriod(period)
+		}
+		u.area.Unlock()
+		if period == 1 {
+			reason = FinishReasonStillLife
+		} else if period > 1 {
+			reason = FinishReasonOscillator
+		}
+	}
+}
+
+//finish switches the universe to the finished state with the reason
+func (u *BaseUniverse) finish(reason FinishReason, period int) {
+	u.state.Lock()
+	u.state.FinishReason = reason
+	u.state.Period = period
+	u.state.Unlock()
+	u.switchRunningState(RunningStateFinished)
+}
+
+//areaModified marks the area as modified outside the nextIteration, should be called with the locked area
+//the generations history is not valid anymore, the edit is recorded to the undo history, the joined edit is merged to the previous one
+func (u *BaseUniverse) areaModified(join bool) {
+	u.area.modified = true
+	if u.cycles != nil {
+		u.cycles.reset()
+	}
+	u.recordHistory(join)
+	u.trackActivity(false)
+}
+
+//recordHistory records the area and the engine field state to the undo history, should be called with the locked area
+//the joined edit is merged to the previous edit if there was no step between them
+func (u *BaseUniverse) recordHistory(join bool) {
+	if u.history == nil {
+		return
+	}
+	var field interface{}
+	if u.fieldState != nil {
+		field = u.fieldState()
+	}
+	u.history.record(u.area.Area, u.state.IterationNum, field, join)
+}
+
+//restore restores the area state from the history with the move function (back or forward)
+//the simulation is stopped, the finish state is reset, so the simulation can be continued from the restored state
+func (u *BaseUniverse) restore(move func(a Area) (iteration int, field interface{}, ok bool)) {
+	if u.history == nil {
+		return
+	}
+	u.stop()
+	u.state.Lock()
+	u.area.Lock()
+	iteration, field, ok := move(u.area.Area)
+	if ok {
+		u.state.IterationNum = iteration
+		u.state.LiveCells = 0
+		if u.restoreField != nil {
+			u.state.LiveCells = u.restoreField(field)
+		} else {
+			u.walkArea(func(x int, y int, e Cell) {
+				if bool(e) {
+					u.state.LiveCells++
+				}
+			})
+		}
+		u.state.FinishReason = FinishReasonNone
+		u.state.Period = 0
+		u.state.Err = nil
+		u.areaModified(false)
+	}
+	u.area.Unlock()
+	u.state.Unlock()
+	if ok {
+		u.switchRunningState(RunningStateManual)
+		u.refreshView()
 	}
 }
 
+//historyStatus returns the status with the undo history depth
+func (u *BaseUniverse) historyStatus(st Status) Status {
+	if u.history != nil {
+		st.Undo, st.Redo = u.history.depth()
+	}
+	return st
+}
+
 //clear clears the unvierse data, reset all counters
 func (u *BaseUniverse) clear() {
 	u.state.Lock()
@@ -358,9 +625,19 @@ func (u *BaseUniverse) clear() {
 
 	u.state.IterationNum = 0
 	u.state.LiveCells = 0
+	u.state.FinishReason = FinishReasonNone
+	u.state.Period = 0
+	u.state.Err = nil
+	u.state.Births, u.state.Deaths = 0, 0
 	u.walkArea(func(x int, y int, e Cell) {
 		u.area.Entities[y][x] = false
 	})
+	//the heat is accumulated since the clear
+	u.activity = nil
+	if u.onClear != nil {
+		u.onClear()
+	}
+	u.areaModified(false)
 	u.state.RunningMode = RunningStateManual
 	u.area.Unlock()
 	u.state.Unlock()
@@ -394,6 +671,27 @@ func (u *BaseUniverse) _nextIteration() (hasLiveEnitities bool, changed bool) {
 	return
 }
 
+//setAdvanced sets the advanced options, the options map is replaced, so the views can read the previous one safely
+//should be called by the universe goroutine, the engines read the options without the lock there
+func (u *BaseUniverse) setAdvanced(values map[string]interface{}) {
+	u.optionsLock.Lock()
+	u.options.SetAdvanced(values)
+	u.optionsLock.Unlock()
+}
+
+//advancedInt returns the integer advanced option or the default value if the option is not set
+func (u *BaseUniverse) advancedInt(name string, def int) int {
+	if v, ok := u.options.Advanced[name].(int); ok {
+		return v
+	}
+	return def
+}
+
+//_stateHash calculates the hash of the area, should be called with the locked area
+func (u *BaseUniverse) _stateHash() uint64 {
+	return areaHash(u.area.Area)
+}
+
 //walkArea walk the entire area and calls the cb function for each cell
 func (u *BaseUniverse) walkArea(cb func(x int, y int, entity Cell)) {
 	for y := range u.area.Entities {
@@ -403,21 +701,22 @@ func (u *BaseUniverse) walkArea(cb func(x int, y int, entity Cell)) {
 	}
 }
 
-//cellNextState calculates the next state for the cell
+//cellNextState calculates the next state for the cell according to the universe rule
 func (u *BaseUniverse) cellNextState(x int, y int) (live bool) {
 	//calculate neighbors
 	liveNeighbours := 0
 	area := u.area.Area
+	topology := u.options.Topology
 	for i := -1; i < 2; i++ {
 		for j := -1; j < 2; j++ {
 			//skip my position
 			if i == 0 && j == 0 {
 				continue
 			}
-			nx := x + i
-			ny := y + j
-			//skip coordinates outside the area
-			if nx < 0 || ny < 0 || nx >= area.Width || ny >= area.Height {
+			//map the neighbour position to the area according to the topology
+			//skip coordinates outside the bounded area
+			nx, ny, ok := topology.Wrap(x+i, y+j, area.Width, area.Height)
+			if !ok {
 				continue
 			}
 			if area.Entities[ny][nx] {
@@ -426,17 +725,7 @@ func (u *BaseUniverse) cellNextState(x int, y int) (live bool) {
 		}
 	}
 
-	if liveNeighbours < 2 {
-		return false
-	} else if liveNeighbours > 3 {
-		return false
-	} else if liveNeighbours == 3 {
-		return true
-	} else if liveNeighbours == 2 && area.Entities[y][x] {
-		return true
-	}
-
-	return false
+	return u.options.Rule.NextState(bool(area.Entities[y][x]), liveNeighbours)
 }
 
 //refreshView calls Refresh event for all registered views
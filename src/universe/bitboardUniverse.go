@@ -0,0 +1,227 @@
+package universe
+
+import (
+	"math/bits"
+	"time"
+)
+
+/*
+	Universe implementation with bit-packed cells (bitboard)
+	each row is packed to the uint64 words, 64 cells per word
+	the neighbours of 64 cells are counted at once by the word-parallel bitwise adders,
+	the counters are represented by 4 bit planes (the count 0..8 in binary)
+	the area buffer is synchronized with the bitboard: it is packed when modified outside the engine
+	and only the changed words are unpacked back after the each iteration
+*/
+
+const wordBits = 64
+
+type BitboardUniverse struct {
+	*BaseUniverse
+	words     int      //words per row
+	board     []uint64 //the current generation, rows one after another
+	next      []uint64 //the next generation buffer
+	zeroRow   []uint64 //the row outside the bounded area
+	lastMask  uint64   //the mask of the used bits in the last word of the row
+	birth     []int    //neighbours counts to born
+	survival  []int    //neighbours counts to survive
+	wrapEdges bool     //edge cells should be recalculated according to the topology
+}
+
+func NewBitboardUniverse(o *Options, stateCh chan Status) Universe {
+	bu := BitboardUniverse{BaseUniverse: NewBaseUniverse(o, stateCh)}
+	//redefine the nextIteration
+	bu.BaseUniverse.nextIteration = bu.nextIteration
+	bu.BaseUniverse.stateHash = bu.stateHash
+	bu.words = (bu.area.Width + wordBits - 1) / wordBits
+	bu.board = make([]uint64, bu.words*bu.area.Height)
+	bu.next = make([]uint64, bu.words*bu.area.Height)
+	bu.zeroRow = make([]uint64, bu.words)
+	bu.lastMask = ^uint64(0)
+	if r := bu.area.Width % wordBits; r != 0 {
+		bu.lastMask = uint64(1)<<uint(r) - 1
+	}
+	for n := 0; n <= 8; n++ {
+		if bu.options.Rule.Birth(n) {
+			bu.birth = append(bu.birth, n)
+		}
+		if bu.options.Rule.Survival(n) {
+			bu.survival = append(bu.survival, n)
+		}
+	}
+	bu.wrapEdges = bu.options.Topology != TopologyBounded
+	bu.options.Advanced["engine"] = "bitboard"
+	return &bu
+}
+
+//nextIteration calculates the next generation on the bitboard and unpacks the changes to the area
+func (bu *BitboardUniverse) nextIteration() (hasLiveEnitities bool, changed bool) {
+	bu.area.Lock()
+	defer bu.area.Unlock()
+	start := time.Now()
+	if bu.area.modified {
+		bu.pack()
+		bu.area.modified = false
+	}
+	h := bu.area.Height
+	n := bu.words
+	for y := 0; y < h; y++ {
+		up, down := bu.zeroRow, bu.zeroRow
+		if y > 0 {
+			up = bu.board[(y-1)*n : y*n]
+		}
+		if y < h-1 {
+			down = bu.board[(y+1)*n : (y+2)*n]
+		}
+		bu.calcRow(up, bu.board[y*n:(y+1)*n], down, bu.next[y*n:(y+1)*n])
+	}
+	if bu.wrapEdges {
+		bu.calcEdges()
+	}
+
+	liveCells := 0
+	for i, w := range bu.next {
+		liveCells += bits.OnesCount64(w)
+		if diff := w ^ bu.board[i]; diff != 0 {
+			changed = true
+			bu.unpack(i, diff)
+		}
+	}
+	bu.board, bu.next = bu.next, bu.board
+
+	bu.state.LiveCells = liveCells
+	bu.state.IterationTime = time.Since(start)
+	hasLiveEnitities = liveCells > 0
+	return
+}
+
+//stateHash calculates the hash of the bitboard
+func (bu *BitboardUniverse) stateHash() uint64 {
+	h := uint64(bu.area.Width)*0x9e3779b97f4a7c15 ^ uint64(bu.area.Height)
+	for _, w := range bu.board {
+		h = hashMix(h, w)
+	}
+	return h
+}
+
+//calcRow calculates the next state for the row of words using the rows above and below
+func (bu *BitboardUniverse) calcRow(up []uint64, row []uint64, down []uint64, out []uint64) {
+	n := len(row)
+	for i := 0; i < n; i++ {
+		var upPrev, rowPrev, downPrev, upNext, rowNext, downNext uint64
+		if i > 0 {
+			upPrev, rowPrev, downPrev = up[i-1], row[i-1], down[i-1]
+		}
+		if i < n-1 {
+			upNext, rowNext, downNext = up[i+1], row[i+1], down[i+1]
+		}
+		//the bit x of the shifted word holds the state of the neighbour x-1 (left) or x+1 (right)
+		ul, ur := up[i]<<1|upPrev>>63, up[i]>>1|upNext<<63
+		rl, rr := row[i]<<1|rowPrev>>63, row[i]>>1|rowNext<<63
+		dl, dr := down[i]<<1|downPrev>>63, down[i]>>1|downNext<<63
+
+		//sum 8 neighbour planes to the 4 bit counter b0..b3
+		s1, c1 := fullAdder(ul, up[i], ur)
+		s2, c2 := fullAdder(rl, rr, dl)
+		s3, c3 := down[i]^dr, down[i]&dr
+		b0, ca := fullAdder(s1, s2, s3)
+		t1, d1 := fullAdder(c1, c2, c3)
+		b1, d2 := t1^ca, t1&ca
+		b2, b3 := d1^d2, d1&d2
+
+		var born, survived uint64
+		for _, c := range bu.birth {
+			born |= countMask(c, b0, b1, b2, b3)
+		}
+		for _, c := range bu.survival {
+			survived |= countMask(c, b0, b1, b2, b3)
+		}
+		out[i] = row[i]&survived | ^row[i]&born
+	}
+	out[n-1] &= bu.lastMask
+}
+
+//calcEdges recalculates the edge cells whose neighbours are wrapped according to the topology
+func (bu *BitboardUniverse) calcEdges() {
+	w, h := bu.area.Width, bu.area.Height
+	for x := 0; x < w; x++ {
+		bu.calcCell(x, 0)
+		bu.calcCell(x, h-1)
+	}
+	for y := 1; y < h-1; y++ {
+		bu.calcCell(0, y)
+		bu.calcCell(w-1, y)
+	}
+}
+
+//calcCell calculates the next state for the single cell counting neighbours one by one
+func (bu *BitboardUniverse) calcCell(x int, y int) {
+	w, h := bu.area.Width, bu.area.Height
+	liveNeighbours := 0
+	for i := -1; i < 2; i++ {
+		for j := -1; j < 2; j++ {
+			if i == 0 && j == 0 {
+				continue
+			}
+			nx, ny, ok := bu.options.Topology.Wrap(x+i, y+j, w, h)
+			if ok && bu.cell(bu.board, nx, ny) {
+				liveNeighbours++
+			}
+		}
+	}
+	idx, bit := y*bu.words+x/wordBits, uint64(1)<<uint(x%wordBits)
+	if bu.options.Rule.NextState(bu.cell(bu.board, x, y), liveNeighbours) {
+		bu.next[idx] |= bit
+	} else {
+		bu.next[idx] &^= bit
+	}
+}
+
+//cell returns the cell state from the bitboard
+func (bu *BitboardUniverse) cell(board []uint64, x int, y int) bool {
+	return board[y*bu.words+x/wordBits]&(uint64(1)<<uint(x%wordBits)) != 0
+}
+
+//pack packs the area to the bitboard
+func (bu *BitboardUniverse) pack() {
+	for i := range bu.board {
+		bu.board[i] = 0
+	}
+	for y, row := range bu.area.Entities {
+		for x, e := range row {
+			if e {
+				bu.board[y*bu.words+x/wordBits] |= uint64(1) << uint(x%wordBits)
+			}
+		}
+	}
+}
+
+//unpack inverts the area cells of the word i marked by the diff bits
+func (bu *BitboardUniverse) unpack(i int, diff uint64) {
+	row := bu.area.Entities[i/bu.words]
+	x0 := (i % bu.words) * wordBits
+	for diff != 0 {
+		b := bits.TrailingZeros64(diff)
+		row[x0+b] = !row[x0+b]
+		diff &= diff - 1
+	}
+}
+
+//fullAdder adds three bit planes, returns the sum and the carry planes
+func fullAdder(a uint64, b uint64, c uint64) (sum uint64, carry uint64) {
+	t := a ^ b
+	return t ^ c, a&b | t&c
+}
+
+//countMask returns the mask of the bits where the counter b0..b3 is equal to n
+func countMask(n int, b0 uint64, b1 uint64, b2 uint64, b3 uint64) uint64 {
+	m := ^uint64(0)
+	for i, b := range [4]uint64{b0, b1, b2, b3} {
+		if n&(1<<uint(i)) != 0 {
+			m &= b
+		} else {
+			m &^= b
+		}
+	}
+	return m
+}
@@ -0,0 +1,76 @@
+package universe
+
+import "math/bits"
+
+//cycleDetector keeps the hashes of the recent generations and detects the repeating ones
+type cycleDetector struct {
+	hashes     []uint64       //ring buffer of the recent generation hashes
+	iterations []int          //iteration numbers of the hashes in the ring buffer
+	pos        int            //the next position in the ring buffer
+	seen       map[uint64]int //hash -> the last iteration with this hash
+}
+
+//newCycleDetector creates the detector keeping the history of the size generations
+func newCycleDetector(size int) *cycleDetector {
+	return &cycleDetector{
+		hashes:     make([]uint64, 0, size),
+		iterations: make([]int, 0, size),
+		seen:       make(map[uint64]int, size),
+	}
+}
+
+//add adds the generation hash, returns the period of the cycle if the generation was seen before, 0 otherwise
+func (c *cycleDetector) add(hash uint64, iteration int) (period int) {
+	if it, ok := c.seen[hash]; ok {
+		period = iteration - it
+	}
+	if len(c.hashes) < cap(c.hashes) {
+		c.hashes = append(c.hashes, hash)
+		c.iterations = append(c.iterations, iteration)
+	} else {
+		//evict the oldest generation
+		old := c.hashes[c.pos]
+		if c.seen[old] == c.iterations[c.pos] {
+			delete(c.seen, old)
+		}
+		c.hashes[c.pos] = hash
+		c.iterations[c.pos] = iteration
+	}
+	c.pos = (c.pos + 1) % cap(c.hashes)
+	c.seen[hash] = iteration
+	return
+}
+
+//reset forgets the history
+func (c *cycleDetector) reset() {
+	c.hashes = c.hashes[:0]
+	c.iterations = c.iterations[:0]
+	c.pos = 0
+	for h := range c.seen {
+		delete(c.seen, h)
+	}
+}
+
+//areaHash calculates the hash of the area cells
+func areaHash(a Area) uint64 {
+	h := uint64(a.Width)*0x9e3779b97f4a7c15 ^ uint64(a.Height)
+	for _, row := range a.Entities {
+		var w uint64
+		for x, e := range row {
+			if e {
+				w |= 1 << uint(x%64)
+			}
+			if x%64 == 63 || x == len(row)-1 {
+				h = hashMix(h, w)
+				w = 0
+			}
+		}
+	}
+	return h
+}
+
+//hashMix mixes the value into the hash
+func hashMix(h uint64, v uint64) uint64 {
+	h ^= v + 0x9e3779b97f4a7c15 + h<<6 + h>>2
+	return bits.RotateLeft64(h*0xff51afd7ed558ccd, 31)
+}
@@ -0,0 +1,100 @@
+package universe
+
+/*
+	The field editing
+	the edits are done by the universe goroutine through the control channel between the steps,
+	so the field can be edited while the simulation is running, every edit is one undo step,
+	the joined edits (the segments of one mouse stroke) done without the steps between them are one undo step
+*/
+
+//Region is the rectangle of the field
+type Region struct {
+	X      int //the top-left corner
+	Y      int
+	Width  int
+	Height int
+}
+
+//Edit is the change of the field cells, the operations are applied in the order of the fields
+type Edit struct {
+	Clear  Region  //the region to clear, the empty region clears nothing
+	Random bool    //fill the cleared region with the random soup of Options.Soup density and symmetry, nothing if the soup is invalid
+	Dead   [][]int //the x,y coordinates of the cells to kill
+	Live   [][]int //the x,y coordinates of the cells to settle
+	Invert [][]int //the x,y coordinates of the cells to invert
+	Join   bool    //merge the edit to the previous edit undo step if there was no step between them
+}
+
+//Edit applies the edit to the field, the cells outside the field are dropped, returns immediately
+func (u *BaseUniverse) Edit(e Edit) {
+	u.controlCh <- func() {
+		u.edit(e)
+	}
+}
+
+//edit applies the edit to the field
+func (u *BaseUniverse) edit(e Edit) {
+	u.area.Lock()
+	r := e.Clear.clip(u.area.Width, u.area.Height)
+	for y := r.Y; y < r.Y+r.Height; y++ {
+		row := u.area.Entities[y]
+		for x := r.X; x < r.X+r.Width; x++ {
+			row[x] = false
+		}
+	}
+	if e.Random && r.Width > 0 && r.Height > 0 && u.options.Soup.Validate() == nil {
+		soup := u.options.Soup
+		soup.X, soup.Y, soup.Width, soup.Height = r.X, r.Y, r.Width, r.Height
+		soup.Seed = u.nextSeed
+		u.nextSeed++
+		u.place(soup.Cells(u.area.Width, u.area.Height), Cell(true))
+	}
+	u.place(e.Dead, Cell(false))
+	u.place(e.Live, Cell(true))
+	for _, v := range e.Invert {
+		if u.inside(v[0], v[1]) {
+			u.area.Entities[v[1]][v[0]] = !u.area.Entities[v[1]][v[0]]
+		}
+	}
+	u.areaModified(e.Join)
+	u.area.Unlock()
+	u.state.LiveCells = u.liveCells()
+	u.refreshView()
+}
+
+//place places the Cell at the x,y positions without recording the edit, returns the number of the dropped cells outside the field
+func (u *BaseUniverse) place(vc [][]int, entity Cell) (dropped int) {
+	for _, v := range vc {
+		if !u.inside(v[0], v[1]) {
+			dropped++
+			continue
+		}
+		u.area.Entities[v[1]][v[0]] = entity
+	}
+	return
+}
+
+//inside reports whether the position is inside the field
+func (u *BaseUniverse) inside(x int, y int) bool {
+	return x >= 0 && y >= 0 && x < u.area.Width && y < u.area.Height
+}
+
+//clip returns the region clipped to the field of size width x height
+func (r Region) clip(width int, height int) Region {
+	if r.X < 0 {
+		r.Width, r.X = r.Width+r.X, 0
+	}
+	if r.Y < 0 {
+		r.Height, r.Y = r.Height+r.Y, 0
+	}
+	if r.X+r.Width > width {
+		r.Width = width - r.X
+	}
+	if r.Y+r.Height > height {
+		r.Height = height - r.Y
+	}
+	if r.Width <= 0 || r.Height <= 0 {
+		return Region{}
+	}
+	return r
+}
@@ -0,0 +1,52 @@
+package universe
+
+import (
+	"errors"
+	"fmt"
+)
+
+//ErrEngineOverloaded is the error of the simulation finished because the engine is too slow for the simulation interval
+var ErrEngineOverloaded = errors.New("the engine is overloaded, increase the interval")
+
+//FinishReason represents the reason why the simulation is finished
+type FinishReason int
+
+const (
+	FinishReasonNone         FinishReason = iota //the simulation is not finished
+	FinishReasonStillLife                        //the generation is the same as the previous one
+	FinishReasonOscillator                       //the generation repeats one of the previous generations
+	FinishReasonExtinct                          //there are no live cells
+	FinishReasonMaxSteps                         //the steps limit is reached
+	FinishReasonSkippedTicks                     //the engine is too slow for the simulation interval
+)
+
+var finishReasonNames = map[FinishReason]string{
+	FinishReasonNone:         "",
+	FinishReasonStillLife:    "still life",
+	FinishReasonOscillator:   "oscillator",
+	FinishReasonExtinct:      "extinct",
+	FinishReasonMaxSteps:     "max steps",
+	FinishReasonSkippedTicks: "skipped ticks",
+}
+
+//String returns the finish reason description
+func (r FinishReason) String() string {
+	return finishReasonNames[r]
+}
+
+//Stabilized reports whether the simulation is finished because the universe reached the final state
+func (r FinishReason) Stabilized() bool {
+	return r == FinishReasonStillLife || r == FinishReasonOscillator || r == FinishReasonExtinct
+}
+
+//FinishDescr returns the description of the finish reason including the oscillator period and the error
+func (s Status) FinishDescr() string {
+	d := s.FinishReason.String()
+	if s.FinishReason == FinishReasonOscillator {
+		d = fmt.Sprintf("%s (period %d)", d, s.Period)
+	}
+	if s.Err != nil {
+		d = fmt.Sprintf("%s: %v", d, s.Err)
+	}
+	return d
+}
@@ -0,0 +1,508 @@
+package universe
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+	Universe implementation with the HashLife algorithm
+	the field is unbounded and represented by the quadtree of canonical (hash-consed) nodes,
+	the identical regions share the same node and the successor of each node is memoized,
+	so the repeating patterns are calculated once and the universe can be advanced by 2^n generations at once
+	the universe area is the window onto the field at the coordinates 0,0 .. Width-1,Height-1
+	the rules with B0 (the empty space must stay empty) and the topologies (the field has no edges) are not supported,
+	NewHashLifeUniverse returns the error on them
+	the node cache limit is soft: the cache is collected after the step, so it can grow beyond the limit during the step
+*/
+
+const (
+	DefHashLifeStepExponent = 0       //default step exponent, the universe is advanced by 2^n generations per step
+	MaxHashLifeStepExponent = 32      //maximum step exponent, keeps the generation counter and the node sizes 2^level inside int
+	DefHashLifeCacheLimit   = 1 << 21 //default number of the nodes in the cache triggering garbage collection after the step
+
+	hashLifeMinLevel = 3 //minimum level of the root node
+)
+
+//the advanced options names
+const (
+	AdvStepExponent  = "Step exponent"
+	AdvNodeCacheSize = "Node cache limit"
+)
+
+//hlNode is the quadtree node, the node of level k represents the square of 2^k x 2^k cells
+//the nodes are canonical: the nodes with the same children are the same object
+type hlNode struct {
+	nw, ne, sw, se *hlNode
+	level          uint
+	population     int
+	id             uint64  //unique node identifier, the node dropped by gc and joined again gets the new id
+	hash           uint64  //hash of the node cells, the same for the nodes with the same cells
+	next           *hlNode //memoized successor: the centre of the node advanced by 2^nextStep generations
+	nextStep       uint
+}
+
+//hlKey is the key of the canonical nodes cache
+type hlKey [4]*hlNode
+
+//hashLifeField is the field state recorded to the undo history
+type hashLifeField struct {
+	root       *hlNode
+	generation int
+}
+
+type HashLifeUniverse struct {
+	*BaseUniverse
+	root       *hlNode
+	cache      map[hlKey]*hlNode
+	emptyNodes []*hlNode
+	dead       *hlNode
+	alive      *hlNode
+	stepExp    uint
+	cacheLimit int
+	generation int
+	gcRuns     int
+	lastID     uint64
+}
+
+//NewHashLifeUniverse creates the HashLife universe, returns the error if the rule or the topology isn't supported
+func NewHashLifeUniverse(o *Options, stateCh chan Status) (Universe, error) {
+	if err := checkUnboundedOptions("hashlife", o); err != nil {
+		return nil, err
+	}
+	hu := HashLifeUniverse{BaseUniverse: NewBaseUniverse(o, stateCh)}
+	//redefine the nextIteration
+	hu.BaseUniverse.nextIteration = hu.nextIteration
+	hu.BaseUniverse.onClear = hu.reset
+	hu.BaseUniverse.stateHash = hu.stateHash
+	hu.BaseUniverse.fieldState = hu.fieldState
+	hu.BaseUniverse.restoreField = hu.restoreField
+	hu.BaseUniverse.stepPeriod = hu.stepPeriod
+	hu.BaseUniverse.fieldCells = hu.fieldCells
+	hu.BaseUniverse.settleField = hu.settleField
+
+	stepExp := hu.advancedInt(AdvStepExponent, DefHashLifeStepExponent)
+	if stepExp < 0 {
+		stepExp = 0
+	} else if stepExp > MaxHashLifeStepExponent {
+		stepExp = MaxHashLifeStepExponent
+	}
+	hu.stepExp = uint(stepExp)
+	hu.cacheLimit = hu.advancedInt(AdvNodeCacheSize, DefHashLifeCacheLimit)
+	hu.dead = &hlNode{id: 1, hash: 1}
+	hu.alive = &hlNode{population: 1, id: 2, hash: 2}
+	hu.lastID = 2
+	hu.reset()
+
+	hu.options.Advanced["engine"] = "hashlife"
+	hu.options.Advanced["Field"] = "unbounded"
+	hu.options.Advanced[AdvStepExponent] = stepExp
+	hu.options.Advanced[AdvNodeCacheSize] = hu.cacheLimit
+	return &hu, nil
+}
+
+//checkUnboundedOptions returns the error if the options aren't supported by the engine with the unbounded field:
+//the empty space must stay empty (no B0 rules) and the field has no edges to glue (the bounded topology only)
+func checkUnboundedOptions(engine string, o *Options) error {
+	if o == nil {
+		return nil
+	}
+	if o.Rule.Birth(0) {
+		return fmt.Errorf("the rules with B0 are not supported by the %s engine", engine)
+	}
+	if o.Topology != TopologyBounded {
+		return fmt.Errorf("the %s engine has the unbounded field, the topology %v is not supported", engine, o.Topology)
+	}
+	return nil
+}
+
+//nextIteration advances the universe by 2^stepExp generations and renders the window to the area
+func (hu *HashLifeUniverse) nextIteration() (hasLiveEnitities bool, changed bool) {
+	hu.area.Lock()
+	defer hu.area.Unlock()
+	start := time.Now()
+	if hu.area.modified {
+		hu.importArea()
+		hu.area.modified = false
+	}
+
+	prev := hu.root
+	hu.root = hu.advance(hu.root, hu.stepExp)
+	hu.generation += 1 << hu.stepExp
+	changed = hu.root != prev
+	if len(hu.cache) > hu.cacheLimit {
+		hu.gc()
+	}
+	if changed {
+		hu.renderArea()
+	}
+
+	hu.state.LiveCells = hu.root.population
+	hu.state.IterationTime = time.Since(start)
+	hu.state.Details = hu.details()
+	hasLiveEnitities = hu.root.population > 0
+	return
+}
+
+//reset resets the field and the nodes cache
+func (hu *HashLifeUniverse) reset() {
+	hu.cache = make(map[hlKey]*hlNode)
+	hu.emptyNodes = []*hlNode{hu.dead}
+	hu.root = hu.empty(hashLifeMinLevel)
+	hu.generation = 0
+	hu.state.Details = hu.details()
+}
+
+//fieldState returns the root node and the generation, the nodes are immutable, so the root keeps the whole field
+func (hu *HashLifeUniverse) fieldState() interface{} {
+	return hashLifeField{root: hu.root, generation: hu.generation}
+}
+
+//restoreField restores the root node and the generation, the cells inside the window are taken from the area
+func (hu *HashLifeUniverse) restoreField(field interface{}) (liveCells int) {
+	if f, ok := field.(hashLifeField); ok {
+		//the nodes dropped from the cache by gc aren't canonical anymore, so the tree is joined again
+		hu.root = hu.intern(f.root, map[*hlNode]*hlNode{})
+		hu.generation = f.generation
+	} else {
+		hu.reset()
+	}
+	hu.importArea()
+	hu.state.Details = hu.details()
+	return hu.root.population
+}
+
+//fieldCells returns the live cells outside the window saved to the snapshot, the window doesn't move
+func (hu *HashLifeUniverse) fieldCells() (originX int, originY int, outside [][]int) {
+	var collect func(n *hlNode, x int, y int)
+	collect = func(n *hlNode, x int, y int) {
+		size := 1 << n.level
+		if n.population == 0 || (x >= 0 && y >= 0 && x+size <= hu.area.Width && y+size <= hu.area.Height) {
+			return
+		}
+		if n.level == 0 {
+			outside = append(outside, []int{x, y})
+			return
+		}
+		half := size / 2
+		collect(n.nw, x, y)
+		collect(n.ne, x+half, y)
+		collect(n.sw, x, y+half)
+		collect(n.se, x+half, y+half)
+	}
+	offset := 1 << (hu.root.level - 1)
+	collect(hu.root, -offset, -offset)
+	return 0, 0, outside
+}
+
+//settleField settles the cells outside the window loaded from the snapshot, the cells inside the window are taken from the area
+//the window doesn't move, so the cells are shifted by the saved window origin
+func (hu *HashLifeUniverse) settleField(originX int, originY int, outside [][]int) (liveCells int) {
+	hu.reset()
+	for _, c := range outside {
+		x, y := c[0]-originX, c[1]-originY
+		//the root node covers the field coordinates -2^(k-1) .. 2^(k-1)-1
+		for x < -1<<(hu.root.level-1) || x >= 1<<(hu.root.level-1) || y < -1<<(hu.root.level-1) || y >= 1<<(hu.root.level-1) {
+			hu.root = hu.expand(hu.root)
+		}
+		offset := 1 << (hu.root.level - 1)
+		hu.root = hu.setCell(hu.root, x+offset, y+offset, true)
+	}
+	hu.importArea()
+	hu.state.Details = hu.details()
+	return hu.root.population
+}
+
+//intern returns the canonical node with the same cells as the node
+func (hu *HashLifeUniverse) intern(n *hlNode, seen map[*hlNode]*hlNode) *hlNode {
+	if n.level == 0 {
+		return n
+	}
+	if c, ok := seen[n]; ok {
+		return c
+	}
+	c := hu.join(hu.intern(n.nw, seen), hu.intern(n.ne, seen), hu.intern(n.sw, seen), hu.intern(n.se, seen))
+	seen[n] = c
+	return c
+}
+
+//stateHash returns the hash of the field cells
+//the node ids aren't used, they change when gc drops the node, and the root is shrunk, so the padding doesn't matter
+func (hu *HashLifeUniverse) stateHash() uint64 {
+	return hu.shrink(hu.root).hash
+}
+
+//stepPeriod returns the period in the generations of the cycle repeating after the steps
+//the period divides steps*2^stepExp, the shortest of steps*2^i repeating the field is returned
+func (hu *HashLifeUniverse) stepPeriod(steps int) int {
+	root := hu.shrink(hu.root)
+	for i := uint(0); i < hu.stepExp; i++ {
+		n := root
+		for s := 0; s < steps; s++ {
+			n = hu.advance(n, i)
+		}
+		if n == root {
+			return steps << i
+		}
+	}
+	return steps << hu.stepExp
+}
+
+//details returns the engine statistics
+func (hu *HashLifeUniverse) details() map[string]interface{} {
+	return map[string]interface{}{
+		"Generation":  hu.generation,
+		"Node cache":  len(hu.cache),
+		"Cache GC":    hu.gcRuns,
+		"Tree level":  hu.root.level,
+		"Gens / step": 1 << hu.stepExp,
+	}
+}
+
+//advance advances the node by 2^j generations, returns the node with the same level and position
+//the result is shrunk to the minimal level keeping the node centre
+func (hu *HashLifeUniverse) advance(n *hlNode, j uint) *hlNode {
+	//the pattern must be inside the central half of the node which level is enough for the step
+	for n.level < j+2 || !hu.isPadded(n) {
+		n = hu.expand(n)
+	}
+	n = hu.successor(hu.expand(n), j)
+	return hu.shrink(n)
+}
+
+//successor returns the centre of the node (level k-1) advanced by 2^j generations, j <= k-2
+func (hu *HashLifeUniverse) successor(n *hlNode, j uint) *hlNode {
+	if n.population == 0 {
+		return hu.empty(n.level - 1)
+	}
+	if j > n.level-2 {
+		j = n.level - 2
+	}
+	if n.next != nil && n.nextStep == j {
+		return n.next
+	}
+	var r *hlNode
+	if n.level == 2 {
+		r = hu.life4x4(n)
+	} else {
+		c1 := hu.successor(n.nw, j)
+		c2 := hu.successor(hu.join(n.nw.ne, n.ne.nw, n.nw.se, n.ne.sw), j)
+		c3 := hu.successor(n.ne, j)
+		c4 := hu.successor(hu.join(n.nw.sw, n.nw.se, n.sw.nw, n.sw.ne), j)
+		c5 := hu.successor(hu.join(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw), j)
+		c6 := hu.successor(hu.join(n.ne.sw, n.ne.se, n.se.nw, n.se.ne), j)
+		c7 := hu.successor(n.sw, j)
+		c8 := hu.successor(hu.join(n.sw.ne, n.se.nw, n.sw.se, n.se.sw), j)
+		c9 := hu.successor(n.se, j)
+		if j < n.level-2 {
+			//the sub-nodes are advanced by the full step already, take their centres
+			r = hu.join(
+				hu.join(c1.se, c2.sw, c4.ne, c5.nw),
+				hu.join(c2.se, c3.sw, c5.ne, c6.nw),
+				hu.join(c4.se, c5.sw, c7.ne, c8.nw),
+				hu.join(c5.se, c6.sw, c8.ne, c9.nw))
+		} else {
+			//the sub-nodes are advanced by the half of the step, advance them by the second half
+			r = hu.join(
+				hu.successor(hu.join(c1, c2, c4, c5), j),
+				hu.successor(hu.join(c2, c3, c5, c6), j),
+				hu.successor(hu.join(c4, c5, c7, c8), j),
+				hu.successor(hu.join(c5, c6, c8, c9), j))
+		}
+	}
+	n.next, n.nextStep = r, j
+	return r
+}
+
+//life4x4 calculates the centre 2x2 of the 4x4 node advanced by one generation
+func (hu *HashLifeUniverse) life4x4(n *hlNode) *hlNode {
+	var cells [4][4]bool
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			cells[y][x] = hu.cell(n, x, y)
+		}
+	}
+	var r [4]*hlNode
+	for i := 0; i < 4; i++ {
+		x, y := 1+i%2, 1+i/2
+		liveNeighbours := 0
+		for dy := -1; dy < 2; dy++ {
+			for dx := -1; dx < 2; dx++ {
+				if (dx != 0 || dy != 0) && cells[y+dy][x+dx] {
+					liveNeighbours++
+				}
+			}
+		}
+		r[i] = hu.dead
+		if hu.options.Rule.NextState(cells[y][x], liveNeighbours) {
+			r[i] = hu.alive
+		}
+	}
+	return hu.join(r[0], r[1], r[2], r[3])
+}
+
+//join returns the canonical node with the children
+func (hu *HashLifeUniverse) join(nw *hlNode, ne *hlNode, sw *hlNode, se *hlNode) *hlNode {
+	key := hlKey{nw, ne, sw, se}
+	if n, ok := hu.cache[key]; ok {
+		return n
+	}
+	n := &hlNode{
+		nw: nw, ne: ne, sw: sw, se: se,
+		level:      nw.level + 1,
+		population: nw.population + ne.population + sw.population + se.population,
+	}
+	hu.lastID++
+	n.id = hu.lastID
+	n.hash = hashMix(hashMix(hashMix(hashMix(uint64(n.level), nw.hash), ne.hash), sw.hash), se.hash)
+	hu.cache[key] = n
+	return n
+}
+
+//empty returns the canonical empty node of the level
+func (hu *HashLifeUniverse) empty(level uint) *hlNode {
+	for uint(len(hu.emptyNodes)) <= level {
+		e := hu.emptyNodes[len(hu.emptyNodes)-1]
+		hu.emptyNodes = append(hu.emptyNodes, hu.join(e, e, e, e))
+	}
+	return hu.emptyNodes[level]
+}
+
+//expand returns the node of the next level with the node placed at the centre
+func (hu *HashLifeUniverse) expand(n *hlNode) *hlNode {
+	e := hu.empty(n.level - 1)
+	return hu.join(
+		hu.join(e, e, e, n.nw),
+		hu.join(e, e, n.ne, e),
+		hu.join(e, n.sw, e, e),
+		hu.join(n.se, e, e, e))
+}
+
+//shrink returns the smallest node with the same centre containing the all live cells
+func (hu *HashLifeUniverse) shrink(n *hlNode) *hlNode {
+	for n.level > hashLifeMinLevel && hu.isPadded(n) {
+		n = hu.join(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw)
+	}
+	return n
+}
+
+//isPadded reports whether all the live cells are inside the central half of the node
+func (hu *HashLifeUniverse) isPadded(n *hlNode) bool {
+	return n.nw.population == n.nw.se.population &&
+		n.ne.population == n.ne.sw.population &&
+		n.sw.population == n.sw.ne.population &&
+		n.se.population == n.se.nw.population
+}
+
+//cell returns the cell state at the position x,y relative to the node top left corner
+func (hu *HashLifeUniverse) cell(n *hlNode, x int, y int) bool {
+	for n.level > 0 {
+		if n.population == 0 {
+			return false
+		}
+		half := 1 << (n.level - 1)
+		switch {
+		case x < half && y < half:
+			n = n.nw
+		case y < half:
+			n, x = n.ne, x-half
+		case x < half:
+			n, y = n.sw, y-half
+		default:
+			n, x, y = n.se, x-half, y-half
+		}
+	}
+	return n == hu.alive
+}
+
+//setCell returns the node with the cell at the position x,y relative to the node top left corner set to the state
+func (hu *HashLifeUniverse) setCell(n *hlNode, x int, y int, alive bool) *hlNode {
+	if n.level == 0 {
+		if alive {
+			return hu.alive
+		}
+		return hu.dead
+	}
+	half := 1 << (n.level - 1)
+	switch {
+	case x < half && y < half:
+		return hu.join(hu.setCell(n.nw, x, y, alive), n.ne, n.sw, n.se)
+	case y < half:
+		return hu.join(n.nw, hu.setCell(n.ne, x-half, y, alive), n.sw, n.se)
+	case x < half:
+		return hu.join(n.nw, n.ne, hu.setCell(n.sw, x, y-half, alive), n.se)
+	}
+	return hu.join(n.nw, n.ne, n.sw, hu.setCell(n.se, x-half, y-half, alive))
+}
+
+//importArea writes the area changes (settled or edited cells) to the field
+func (hu *HashLifeUniverse) importArea() {
+	//the root node covers the field coordinates -2^(k-1) .. 2^(k-1)-1
+	for 1<<(hu.root.level-1) < hu.area.Width || 1<<(hu.root.level-1) < hu.area.Height {
+		hu.root = hu.expand(hu.root)
+	}
+	offset := 1 << (hu.root.level - 1)
+	for y, row := range hu.area.Entities {
+		for x, e := range row {
+			if hu.cell(hu.root, x+offset, y+offset) != bool(e) {
+				hu.root = hu.setCell(hu.root, x+offset, y+offset, bool(e))
+			}
+		}
+	}
+}
+
+//renderArea renders the window onto the field to the area
+func (hu *HashLifeUniverse) renderArea() {
+	for y := range hu.area.Entities {
+		row := hu.area.Entities[y]
+		for x := range row {
+			row[x] = false
+		}
+	}
+	offset := 1 << (hu.root.level - 1)
+	hu.renderNode(hu.root, -offset, -offset)
+}
+
+//renderNode renders the node with the top left corner at the area position x,y
+func (hu *HashLifeUniverse) renderNode(n *hlNode, x int, y int) {
+	size := 1 << n.level
+	if n.population == 0 || x >= hu.area.Width || y >= hu.area.Height || x+size <= 0 || y+size <= 0 {
+		return
+	}
+	if n.level == 0 {
+		hu.area.Entities[y][x] = true
+		return
+	}
+	half := size / 2
+	hu.renderNode(n.nw, x, y)
+	hu.renderNode(n.ne, x+half, y)
+	hu.renderNode(n.sw, x, y+half)
+	hu.renderNode(n.se, x+half, y+half)
+}
+
+//gc removes the nodes unreachable from the root from the cache and drops the memoized successors
+func (hu *HashLifeUniverse) gc() {
+	old := hu.cache
+	hu.cache = make(map[hlKey]*hlNode, len(old)/2)
+	var keep func(n *hlNode)
+	keep = func(n *hlNode) {
+		if n.level == 0 {
+			return
+		}
+		key := hlKey{n.nw, n.ne, n.sw, n.se}
+		if _, ok := hu.cache[key]; ok {
+			return
+		}
+		n.next = nil
+		hu.cache[key] = n
+		keep(n.nw)
+		keep(n.ne)
+		keep(n.sw)
+		keep(n.se)
+	}
+	keep(hu.root)
+	for _, e := range hu.emptyNodes {
+		keep(e)
+	}
+	hu.gcRuns++
+}
@@ -0,0 +1,204 @@
+package universe
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+/*
+	The generations and edits history for undo/redo
+	the history keeps the copy of the last recorded area state and the bounded ring of the deltas between the states,
+	the delta is the list of the flipped cells encoded as the varint gaps between their indexes,
+	so the step changing a few cells takes a few bytes
+	the engines with the unbounded field (sparse, hashlife) keep the cells outside the area,
+	their field state (the window origin, the cells outside the window, the generation) is recorded with every delta,
+	so undo restores the whole field, not only the window
+	every recording compares the whole area with the last state, so the recording costs O(width x height) per step
+	the joined edit (the next segment of the mouse stroke) is merged to the previous edit delta if there was no step between them,
+	so the whole stroke is undone at once
+*/
+
+//history is the bounded history of the area states
+type history struct {
+	sync.Mutex
+	last      Area           //the last recorded area state
+	iteration int            //the iteration number of the last recorded state
+	undo      []historyDelta //ring buffer of the deltas to the previous states
+	pos       int            //the next position in the undo ring buffer
+	size      int            //number of the deltas in the undo ring buffer
+	redo      []historyDelta //the stack of the undone deltas
+	field     interface{}    //the engine field state of the last recorded state, nil for the bounded engines
+	joinable  bool           //the last delta is the edit, the next joined edit is merged to it
+}
+
+//historyDelta is the difference between two area states
+type historyDelta struct {
+	cells []byte //the flipped cells indexes gaps, varint encoded
+	from  int    //the iteration number of the previous state
+	to    int    //the iteration number of the next state
+	//the engine field states of the previous and the next states
+	fromField interface{}
+	toField   interface{}
+}
+
+//newHistory creates the history keeping up to size previous states of the area
+func newHistory(size int, a Area) *history {
+	h := &history{
+		last: createArea(a.Width, a.Height),
+		undo: make([]historyDelta, size),
+	}
+	for y := range a.Entities {
+		copy(h.last.Entities[y], a.Entities[y])
+	}
+	return h
+}
+
+//record records the area and the engine field state if it differs from the last recorded one, the redo history is discarded then
+//the field states can't be compared, so the field state is recorded on every new iteration
+//the joined edit is merged to the last delta if it's the edit of the same iteration
+//should be called with the locked area
+func (h *history) record(a Area, iteration int, field interface{}, join bool) {
+	h.Lock()
+	defer h.Unlock()
+	cells := h.diff(a)
+	switch {
+	case join && h.joinable && h.size > 0 && iteration == h.iteration:
+		d := &h.undo[(h.pos-1+len(h.undo))%len(h.undo)]
+		d.cells = mergeCells(d.cells, cells)
+		d.toField = field
+	case len(cells) > 0 || (field != nil && iteration != h.iteration):
+		h.undo[h.pos] = historyDelta{cells: cells, from: h.iteration, to: iteration, fromField: h.field, toField: field}
+		h.pos = (h.pos + 1) % len(h.undo)
+		if h.size < len(h.undo) {
+			h.size++
+		}
+		h.redo = h.redo[:0]
+		h.joinable = iteration == h.iteration
+	case !join || iteration != h.iteration:
+		h.joinable = false
+	}
+	h.iteration = iteration
+	h.field = field
+}
+
+//back restores up to n previous states to the area, returns the iteration number and the engine field state of the restored state
+//ok is false if there is nothing to undo
+//should be called with the locked area
+func (h *history) back(a Area, n int) (iteration int, field interface{}, ok bool) {
+	h.Lock()
+	defer h.Unlock()
+	h.joinable = false
+	for ; n > 0 && h.size > 0; n-- {
+		h.pos = (h.pos - 1 + len(h.undo)) % len(h.undo)
+		h.size--
+		d := h.undo[h.pos]
+		h.undo[h.pos] = historyDelta{}
+		h.apply(a, d)
+		h.iteration = d.from
+		h.field = d.fromField
+		h.redo = append(h.redo, d)
+		ok = true
+	}
+	return h.iteration, h.field, ok
+}
+
+//forward reapplies up to n undone states to the area, returns the iteration number and the engine field state of the restored state
+//ok is false if there is nothing to redo
+//should be called with the locked area
+func (h *history) forward(a Area, n int) (iteration int, field interface{}, ok bool) {
+	h.Lock()
+	defer h.Unlock()
+	h.joinable = false
+	for ; n > 0 && len(h.redo) > 0; n-- {
+		d := h.redo[len(h.redo)-1]
+		h.redo = h.redo[:len(h.redo)-1]
+		h.apply(a, d)
+		h.iteration = d.to
+		h.field = d.toField
+		h.undo[h.pos] = d
+		h.pos = (h.pos + 1) % len(h.undo)
+		h.size++
+		ok = true
+	}
+	return h.iteration, h.field, ok
+}
+
+//depth returns the number of the states available to undo and to redo
+func (h *history) depth() (undo int, redo int) {
+	h.Lock()
+	defer h.Unlock()
+	return h.size, len(h.redo)
+}
+
+//diff encodes the cells differing from the last recorded state and updates the last state
+func (h *history) diff(a Area) []byte {
+	var cells []byte
+	buf := make([]byte, binary.MaxVarintLen64)
+	next := 0
+	for y, row := range a.Entities {
+		last := h.last.Entities[y]
+		for x, e := range row {
+			if e == last[x] {
+				continue
+			}
+			last[x] = e
+			i := y*a.Width + x
+			cells = append(cells, buf[:binary.PutUvarint(buf, uint64(i-next))]...)
+			next = i + 1
+		}
+	}
+	return cells
+}
+
+//apply flips the delta cells in the area and in the last recorded state
+func (h *history) apply(a Area, d historyDelta) {
+	next := 0
+	for b := d.cells; len(b) > 0; {
+		gap, n := binary.Uvarint(b)
+		b = b[n:]
+		i := next + int(gap)
+		x, y := i%a.Width, i/a.Width
+		a.Entities[y][x] = !a.Entities[y][x]
+		h.last.Entities[y][x] = a.Entities[y][x]
+		next = i + 1
+	}
+}
+
+//mergeCells returns the encoded cells flipped by one of the two deltas, the cells flipped by both are flipped back
+func mergeCells(c1 []byte, c2 []byte) []byte {
+	i1, i2 := cellIndexes(c1), cellIndexes(c2)
+	var cells []byte
+	buf := make([]byte, binary.MaxVarintLen64)
+	next := 0
+	add := func(i int) {
+		cells = append(cells, buf[:binary.PutUvarint(buf, uint64(i-next))]...)
+		next = i + 1
+	}
+	for len(i1) > 0 || len(i2) > 0 {
+		switch {
+		case len(i2) == 0 || (len(i1) > 0 && i1[0] < i2[0]):
+			add(i1[0])
+			i1 = i1[1:]
+		case len(i1) == 0 || i2[0] < i1[0]:
+			add(i2[0])
+			i2 = i2[1:]
+		default:
+			i1, i2 = i1[1:], i2[1:]
+		}
+	}
+	return cells
+}
+
+//cellIndexes decodes the varint gaps of the delta cells to the ascending cells indexes
+func cellIndexes(cells []byte) []int {
+	var indexes []int
+	next := 0
+	for b := cells; len(b) > 0; {
+		gap, n := binary.Uvarint(b)
+		b = b[n:]
+		i := next + int(gap)
+		indexes = append(indexes, i)
+		next = i + 1
+	}
+	return indexes
+}
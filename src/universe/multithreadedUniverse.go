@@ -1,27 +1,43 @@
 package universe
 
 import (
+	"fmt"
+	"runtime"
 	"sync"
 	"time"
 )
 
 /*
 	Universe implementation with multithreaded computation algorithm
-	the field is splitted into the areas each of which is computed by individual goroutine
+	the field is splitted into the tiles (areas) which are computed by the pool of long-lived worker goroutines
+	the tiles are distributed between the workers evenly at the start of each iteration,
+	the worker which finished its own tiles steals the tiles from the queues of the other workers,
+	so the sparse regions don't leave the cores idle
 */
 
 const (
-	DefWorkers          = 10 //default workers
-	DefMinRowsPerWorker = 3  //minimum rows for one worker
+	DefTileSize = 64 //default tile width and height
+
+	AdvWorkers  = "Workers"   //advanced option: the number of the workers, runtime.NumCPU() by default
+	AdvTileSize = "Tile size" //advanced option: the tile width and height
+)
+
+const (
+	//Deprecated: the number of the workers is set by the AdvWorkers advanced option
+	DefWorkers = 10
+	//Deprecated: the field is splitted into the tiles of AdvTileSize, not into the rows
+	DefMinRowsPerWorker = 3
 )
 
 type MultithreadedUniverse struct {
 	*BaseUniverse
-	workers   int
+	workers   []*worker
 	workAreas []workArea
+	wg        sync.WaitGroup
+	closeOnce sync.Once
 }
 
-//workArea describe the working area for the worker
+//workArea describe the working area (tile) for the worker
 type workArea struct {
 	x1        int
 	y1        int
@@ -32,6 +48,16 @@ type workArea struct {
 	changed   bool
 }
 
+//worker is the long-lived goroutine calculating the tiles from its queue
+type worker struct {
+	sync.Mutex
+	queue  []int     //indexes of the tiles to calculate, the owner takes from the head, the thieves steal from the tail
+	start  chan bool //starts the iteration, closed to stop the worker
+	busy   time.Duration
+	tiles  int
+	stolen int
+}
+
 //newWorkArea creates new work area
 func newWorkArea(x1 int, y1 int, x2 int, y2 int) workArea {
 	return workArea{
@@ -50,60 +76,154 @@ func NewMultithreadedUniverse(o *Options, stateCh chan Status) Universe {
 	//redefine the nextIteration
 	mu.BaseUniverse.nextIteration = mu.nextIteration
 
-	mu.workers = DefWorkers
-	linesPerWorker := mu.area.Height / mu.workers
-	if linesPerWorker < DefMinRowsPerWorker {
-		linesPerWorker = DefMinRowsPerWorker
-	} else if linesPerWorker*mu.workers < mu.area.Height {
-		linesPerWorker++
+	workers := mu.advancedInt(AdvWorkers, runtime.NumCPU())
+	if workers < 1 {
+		workers = 1
+	}
+	tileSize := mu.advancedInt(AdvTileSize, DefTileSize)
+	if tileSize < 1 {
+		tileSize = DefTileSize
 	}
-	mu.workAreas = make([]workArea, 0, mu.workers)
-	for y1 := 0; y1 < mu.area.Height; y1 += linesPerWorker {
-		y2 := y1 + linesPerWorker - 1
+	mu.workAreas = make([]workArea, 0)
+	for y1 := 0; y1 < mu.area.Height; y1 += tileSize {
+		y2 := y1 + tileSize - 1
 		if y2 > mu.area.Height-1 {
 			y2 = mu.area.Height - 1
 		}
-		mu.workAreas = append(mu.workAreas, newWorkArea(0, y1, mu.area.Width-1, y2))
+		for x1 := 0; x1 < mu.area.Width; x1 += tileSize {
+			x2 := x1 + tileSize - 1
+			if x2 > mu.area.Width-1 {
+				x2 = mu.area.Width - 1
+			}
+			mu.workAreas = append(mu.workAreas, newWorkArea(x1, y1, x2, y2))
+		}
+	}
+	mu.workers = make([]*worker, workers)
+	for i := range mu.workers {
+		mu.workers[i] = &worker{start: make(chan bool)}
+		go mu.workerLoop(i)
 	}
-	mu.workers = len(mu.workAreas)
 	mu.options.Advanced["engine"] = "multithreaded"
-	mu.options.Advanced["Workers"] = mu.workers
-	mu.options.Advanced["Rows per worker"] = linesPerWorker
+	mu.options.Advanced[AdvWorkers] = workers
+	mu.options.Advanced[AdvTileSize] = tileSize
+	mu.options.Advanced["Tiles"] = len(mu.workAreas)
 	return &mu
 }
 
+//Close stops the workers and the main loop, returns when the workers are stopped
+//the workers are the long-lived goroutines like the main loop, so Close must be called to release them,
+//the next calls do nothing
+func (mu *MultithreadedUniverse) Close() {
+	mu.closeOnce.Do(func() {
+		done := make(chan bool)
+		//the workers are stopped by the main loop, so it doesn't happen in the middle of the iteration
+		mu.controlCh <- func() {
+			for _, w := range mu.workers {
+				close(w.start)
+			}
+			close(done)
+		}
+		<-done
+		mu.BaseUniverse.Close()
+	})
+}
+
 //nextIteration calcualtes next state for the universe
-//starts goroutines, waiting for finishing and update all related metrics
+//distributes the tiles between the workers, waits for finishing and update all related metrics
 func (mu *MultithreadedUniverse) nextIteration() (hasLiveEntities bool, changed bool) {
 	mu.area.Lock()
 	defer mu.area.Unlock()
 	start := time.Now()
 	liveCells := 0
-	var waitGroup sync.WaitGroup
+
+	//the contiguous ranges of the tiles are assigned to the workers to keep the memory locality
+	n := len(mu.workers)
+	for i, w := range mu.workers {
+		w.queue = w.queue[:0]
+		for t := i * len(mu.workAreas) / n; t < (i+1)*len(mu.workAreas)/n; t++ {
+			w.queue = append(w.queue, t)
+		}
+		w.busy, w.tiles, w.stolen = 0, 0, 0
+	}
+	mu.wg.Add(n)
+	for _, w := range mu.workers {
+		w.start <- true
+	}
+	mu.wg.Wait()
+
 	for i := range mu.workAreas {
 		workArea := &mu.workAreas[i]
-		waitGroup.Add(1)
-		go func() {
-			mu.calcArea(workArea)
-			waitGroup.Done()
-		}()
-	}
-	waitGroup.Wait()
-	for _, workArea := range mu.workAreas {
 		mu.writeArea(workArea)
 		liveCells += workArea.liveCells
 		changed = changed || workArea.changed
 	}
 	mu.state.LiveCells = liveCells
 	mu.state.IterationTime = time.Since(start)
+	mu.state.Details = mu.details()
 	hasLiveEntities = liveCells > 0
 	return
 }
 
+//workerLoop is the worker goroutine, calculates the tiles on each start signal
+func (mu *MultithreadedUniverse) workerLoop(i int) {
+	w := mu.workers[i]
+	for range w.start {
+		start := time.Now()
+		for {
+			t, stolen := mu.nextTile(i)
+			if t < 0 {
+				break
+			}
+			mu.calcArea(&mu.workAreas[t])
+			w.tiles++
+			if stolen {
+				w.stolen++
+			}
+		}
+		w.busy = time.Since(start)
+		mu.wg.Done()
+	}
+}
+
+//nextTile returns the next tile for the worker i from its own queue or steals it from the other worker
+//returns -1 if there are no more tiles
+func (mu *MultithreadedUniverse) nextTile(i int) (tile int, stolen bool) {
+	w := mu.workers[i]
+	w.Lock()
+	if len(w.queue) > 0 {
+		tile = w.queue[0]
+		w.queue = w.queue[1:]
+		w.Unlock()
+		return tile, false
+	}
+	w.Unlock()
+	for j := 1; j < len(mu.workers); j++ {
+		victim := mu.workers[(i+j)%len(mu.workers)]
+		victim.Lock()
+		if l := len(victim.queue); l > 0 {
+			tile = victim.queue[l-1]
+			victim.queue = victim.queue[:l-1]
+			victim.Unlock()
+			return tile, true
+		}
+		victim.Unlock()
+	}
+	return -1, false
+}
+
+//details returns the per-worker statistics of the last iteration
+func (mu *MultithreadedUniverse) details() map[string]interface{} {
+	d := make(map[string]interface{}, len(mu.workers))
+	for i, w := range mu.workers {
+		d[fmt.Sprintf("Worker %02d", i+1)] = fmt.Sprintf("%v, %d tiles (%d stolen)", w.busy.Round(time.Microsecond), w.tiles, w.stolen)
+	}
+	return d
+}
+
 //writeArea writes workArea buffer to Universe's area buffer
-func (mu *MultithreadedUniverse) writeArea(wa workArea) {
+func (mu *MultithreadedUniverse) writeArea(wa *workArea) {
 	for y := range wa.tmpBuff.Entities {
-		copy(mu.area.Entities[wa.y1+y][wa.x1:wa.x2], wa.tmpBuff.Entities[y])
+		copy(mu.area.Entities[wa.y1+y][wa.x1:wa.x2+1], wa.tmpBuff.Entities[y])
 	}
 }
 
@@ -0,0 +1,116 @@
+package universe
+
+import (
+	"fmt"
+	"strings"
+)
+
+//Rule represents the cellular automaton rule in the B/S notation (life-like rules)
+//birth and survival are bitmasks: bit N is set when the cell is born (survives) with N live neighbours
+type Rule struct {
+	birth    uint16
+	survival uint16
+	name     string
+}
+
+//DefRule is the Conway's Game of Life rule
+const DefRule = "B3/S23"
+
+//ConwayRule is the classic Conway's Game of Life rule B3/S23
+var ConwayRule = MustParseRule(DefRule)
+
+//ParseRule parses the rulestring in the B/S notation, for example "B3/S23", "B36/S23", "B2/S"
+//the S/B notation without prefixes ("23/3") is also accepted
+func ParseRule(s string) (Rule, error) {
+	r := Rule{}
+	rs := strings.ToUpper(strings.TrimSpace(s))
+	parts := strings.Split(rs, "/")
+	if len(parts) != 2 {
+		return r, fmt.Errorf("invalid rule %q: expected the B/S notation, for example %s", s, DefRule)
+	}
+	var b, sv string
+	switch {
+	case strings.HasPrefix(parts[0], "B") && strings.HasPrefix(parts[1], "S"):
+		b, sv = parts[0][1:], parts[1][1:]
+	case strings.HasPrefix(parts[0], "S") && strings.HasPrefix(parts[1], "B"):
+		sv, b = parts[0][1:], parts[1][1:]
+	case !strings.ContainsAny(rs, "BS"):
+		//the old S/B notation, for example 23/3
+		sv, b = parts[0], parts[1]
+	default:
+		return r, fmt.Errorf("invalid rule %q: expected the B/S notation, for example %s", s, DefRule)
+	}
+
+	var err error
+	if r.birth, err = parseRuleDigits(b); err != nil {
+		return r, fmt.Errorf("invalid rule %q: %v", s, err)
+	}
+	if r.survival, err = parseRuleDigits(sv); err != nil {
+		return r, fmt.Errorf("invalid rule %q: %v", s, err)
+	}
+	r.name = "B" + ruleDigits(r.birth) + "/S" + ruleDigits(r.survival)
+	return r, nil
+}
+
+//MustParseRule is like ParseRule but panics if the rulestring cannot be parsed
+func MustParseRule(s string) Rule {
+	r, err := ParseRule(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+//String returns the rule in the canonical B/S notation
+func (r Rule) String() string {
+	return r.name
+}
+
+//IsZero reports whether the rule is not initialized
+func (r Rule) IsZero() bool {
+	return r.name == ""
+}
+
+//Birth reports whether the dead cell with n live neighbours becomes alive
+func (r Rule) Birth(n int) bool {
+	return r.birth&(1<<uint(n)) != 0
+}
+
+//Survival reports whether the live cell with n live neighbours stays alive
+func (r Rule) Survival(n int) bool {
+	return r.survival&(1<<uint(n)) != 0
+}
+
+//NextState returns the next state of the cell with n live neighbours
+func (r Rule) NextState(alive bool, n int) bool {
+	if alive {
+		return r.survival&(1<<uint(n)) != 0
+	}
+	return r.birth&(1<<uint(n)) != 0
+}
+
+//parseRuleDigits converts the neighbours count digits to the bitmask
+func parseRuleDigits(s string) (mask uint16, err error) {
+	for _, c := range s {
+		if c < '0' || c > '8' {
+			return 0, fmt.Errorf("unexpected symbol %q, neighbours count must be in range 0..8", c)
+		}
+		bit := uint16(1) << uint(c-'0')
+		if mask&bit != 0 {
+			return 0, fmt.Errorf("duplicated neighbours count %q", c)
+		}
+		mask |= bit
+	}
+	return
+}
+
+//ruleDigits converts the bitmask to the neighbours count digits
+func ruleDigits(mask uint16) string {
+	var b strings.Builder
+	for i := 0; i <= 8; i++ {
+		if mask&(1<<uint(i)) != 0 {
+			b.WriteByte(byte('0' + i))
+		}
+	}
+	return b.String()
+}
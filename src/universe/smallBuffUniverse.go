@@ -7,11 +7,14 @@ import "time"
 	nextIteration uses small buffer to store the current and previous lines only.
     the first line of this buffer is copied to the main buffer as calculating moves to the next line
 	also here we have small optimization to reduce memory copying
+	with non-bounded topologies the edge cells are calculated in advance, before the main buffer is overwritten,
+	since their neighbours are wrapped to the opposite edges
 */
 
 type SmallBuffUniverse struct {
 	*BaseUniverse
 	tmpBuff Area
+	edges   Area //next states of the edge cells: top row, bottom row, left column, right column
 }
 
 func NewSmallBuffUniverse(o *Options, stateCh chan Status) Universe {
@@ -19,6 +22,14 @@ func NewSmallBuffUniverse(o *Options, stateCh chan Status) Universe {
 	//redefine the nextIteration
 	su.BaseUniverse.nextIteration = su.nextIteration
 	su.tmpBuff = createArea(su.area.Width, 2)
+	if su.options.Topology != TopologyBounded {
+		su.edges = Area{Width: su.area.Width, Height: su.area.Height, Entities: [][]Cell{
+			make([]Cell, su.area.Width),
+			make([]Cell, su.area.Width),
+			make([]Cell, su.area.Height),
+			make([]Cell, su.area.Height),
+		}}
+	}
 	su.options.Advanced["engine"] = "smallBuff"
 	return &su
 }
@@ -28,9 +39,18 @@ func (su *SmallBuffUniverse) nextIteration() (hasLiveEnitities bool, changed boo
 	defer su.area.Unlock()
 	start := time.Now()
 	liveCells := 0
+	edges := su.edges.Entities != nil
+	if edges {
+		su.calcEdges()
+	}
 	for y := range su.area.Entities {
 		for x := range su.area.Entities[y] {
-			nextState := su.cellNextState(x, y)
+			var nextState bool
+			if edges && (y == 0 || y == su.area.Height-1 || x == 0 || x == su.area.Width-1) {
+				nextState = bool(su.edgeState(x, y))
+			} else {
+				nextState = su.cellNextState(x, y)
+			}
 			if nextState {
 				liveCells++
 			}
@@ -48,3 +68,29 @@ func (su *SmallBuffUniverse) nextIteration() (hasLiveEnitities bool, changed boo
 	hasLiveEnitities = liveCells > 0
 	return
 }
+
+//calcEdges calculates the next states for the edge cells
+func (su *SmallBuffUniverse) calcEdges() {
+	w, h := su.area.Width, su.area.Height
+	for x := 0; x < w; x++ {
+		su.edges.Entities[0][x] = Cell(su.cellNextState(x, 0))
+		su.edges.Entities[1][x] = Cell(su.cellNextState(x, h-1))
+	}
+	for y := 0; y < h; y++ {
+		su.edges.Entities[2][y] = Cell(su.cellNextState(0, y))
+		su.edges.Entities[3][y] = Cell(su.cellNextState(w-1, y))
+	}
+}
+
+//edgeState returns the precalculated next state for the edge cell
+func (su *SmallBuffUniverse) edgeState(x int, y int) Cell {
+	switch {
+	case y == 0:
+		return su.edges.Entities[0][x]
+	case y == su.area.Height-1:
+		return su.edges.Entities[1][x]
+	case x == 0:
+		return su.edges.Entities[2][y]
+	}
+	return su.edges.Entities[3][y]
+}
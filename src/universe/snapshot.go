@@ -0,0 +1,371 @@
+package universe
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+/*
+	The simulation snapshots
+	the snapshot keeps the options, the iteration number and the area cells, two formats are supported:
+	 - JSON with the area rows as the strings of '.' and 'O' chars, human readable, used for the small fields
+	 - binary: the magic bytes, the JSON header with the options and the flate compressed bitmap of the cells
+	the format is detected on loading by the first bytes
+	the unbounded engines save the window origin and the live cells outside the window too (the header list in JSON,
+	the varint coordinates after the bitmap in the binary format), so the whole field is restored
+	the advanced options (the engine settings, the soup seed) are informational only, they aren't restored:
+	the engine and its settings are chosen when the universe is created
+*/
+
+const (
+	SnapshotVersion       = 2       //the current snapshot format version, 2 keeps the field outside the window
+	SnapshotJSONMaxCells  = 1 << 14 //the maximum field size (width * height) saved in the JSON format
+	snapshotFormat        = "simlife snapshot"
+	snapshotMaxHeaderSize = 1 << 20 //protects against malformed data
+	snapshotMaxCells      = 1 << 28 //the maximum field size (width * height), protects against malformed data
+	snapshotMaxCoordinate = 1 << 30 //the maximum absolute coordinate of the cells outside the field, protects against malformed data
+)
+
+//snapshotMagic is the binary snapshot signature
+var snapshotMagic = []byte("SIMLIFE\x00")
+
+//Snapshot is the universe state which can be saved and restored
+type Snapshot struct {
+	Options      Options
+	IterationNum int
+	Area         Area
+	OriginX      int //the field coordinates of the area 0,0 cell, the unbounded engines only
+	OriginY      int
+	Outside      [][]int //the live cells outside the area in the field coordinates, the unbounded engines only
+}
+
+//snapshotHeader is the serialized form of the snapshot without the cells
+type snapshotHeader struct {
+	Format    string                 `json:"format"`
+	Version   int                    `json:"version"`
+	Iteration int                    `json:"iteration"`
+	Options   snapshotOptions        `json:"options"`
+	Rows      []string               `json:"rows,omitempty"` //the area rows, JSON format only
+	Advanced  map[string]interface{} `json:"advanced,omitempty"`
+	OriginX   int                    `json:"originX,omitempty"`
+	OriginY   int                    `json:"originY,omitempty"`
+	Outside   [][]int                `json:"outside,omitempty"` //the live cells outside the area, JSON format only
+}
+
+type snapshotOptions struct {
+	Width           int    `json:"width"`
+	Height          int    `json:"height"`
+	Interval        string `json:"interval"`
+	MaxSteps        int    `json:"maxSteps"`
+	MaxSkippedTicks int    `json:"maxSkippedTicks"`
+	Rule            string `json:"rule"`
+	Topology        string `json:"topology"`
+	CycleHistory    int    `json:"cycleHistory"`
+	History         int    `json:"history"`
+}
+
+//WriteSnapshot writes the snapshot, the small fields are written in JSON, the large ones in the binary format
+func WriteSnapshot(w io.Writer, s Snapshot) error {
+	o := s.Options
+	h := snapshotHeader{
+		Format:    snapshotFormat,
+		Version:   SnapshotVersion,
+		Iteration: s.IterationNum,
+		Options: snapshotOptions{
+			Width:           s.Area.Width,
+			Height:          s.Area.Height,
+			Interval:        o.Interval.String(),
+			MaxSteps:        o.MaxSteps,
+			MaxSkippedTicks: o.MaxSkippedTicks,
+			Rule:            o.Rule.String(),
+			Topology:        o.Topology.String(),
+			CycleHistory:    o.CycleHistory,
+			History:         o.History,
+		},
+		Advanced: o.Advanced,
+		OriginX:  s.OriginX,
+		OriginY:  s.OriginY,
+	}
+	if s.Area.Width*s.Area.Height <= SnapshotJSONMaxCells {
+		h.Outside = s.Outside
+		h.Rows = make([]string, len(s.Area.Entities))
+		row := make([]byte, s.Area.Width)
+		for y, r := range s.Area.Entities {
+			for x, e := range r {
+				row[x] = '.'
+				if e {
+					row[x] = 'O'
+				}
+			}
+			h.Rows[y] = string(row)
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(h)
+	}
+
+	header, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	_, _ = bw.Write(snapshotMagic)
+	buf := make([]byte, 2*binary.MaxVarintLen64)
+	_, _ = bw.Write(buf[:binary.PutUvarint(buf, uint64(len(header)))])
+	_, _ = bw.Write(header)
+	fw, err := flate.NewWriter(bw, flate.BestCompression)
+	if err != nil {
+		return err
+	}
+	row := make([]byte, (s.Area.Width+7)/8)
+	for _, r := range s.Area.Entities {
+		for i := range row {
+			row[i] = 0
+		}
+		for x, e := range r {
+			if e {
+				row[x/8] |= 1 << uint(x%8)
+			}
+		}
+		if _, err = fw.Write(row); err != nil {
+			return err
+		}
+	}
+	n := binary.PutUvarint(buf, uint64(len(s.Outside)))
+	if _, err = fw.Write(buf[:n]); err != nil {
+		return err
+	}
+	for _, c := range s.Outside {
+		n = binary.PutVarint(buf, int64(c[0]))
+		n += binary.PutVarint(buf[n:], int64(c[1]))
+		if _, err = fw.Write(buf[:n]); err != nil {
+			return err
+		}
+	}
+	if err = fw.Close(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+//ReadSnapshot reads the snapshot in any supported format
+func ReadSnapshot(r io.Reader) (s Snapshot, err error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(len(snapshotMagic))
+	var h snapshotHeader
+	binaryFormat := bytes.Equal(head, snapshotMagic)
+	if binaryFormat {
+		_, _ = br.Discard(len(snapshotMagic))
+		var size uint64
+		size, err = binary.ReadUvarint(br)
+		if err != nil || size > snapshotMaxHeaderSize {
+			return s, errors.New("snapshot: invalid header")
+		}
+		header := make([]byte, size)
+		if _, err = io.ReadFull(br, header); err != nil {
+			return s, fmt.Errorf("snapshot: %v", err)
+		}
+		err = json.Unmarshal(header, &h)
+	} else {
+		err = json.NewDecoder(br).Decode(&h)
+	}
+	if err != nil {
+		return s, fmt.Errorf("snapshot: %v", err)
+	}
+	if h.Format != snapshotFormat {
+		return s, errors.New("snapshot: unknown format")
+	}
+	if h.Version < 1 || h.Version > SnapshotVersion {
+		return s, fmt.Errorf("snapshot: unsupported version %d", h.Version)
+	}
+	if s.Options, err = h.Options.options(); err != nil {
+		return s, fmt.Errorf("snapshot: %v", err)
+	}
+	s.Options.Advanced = advancedFromJSON(h.Advanced)
+	s.IterationNum = h.Iteration
+	s.OriginX, s.OriginY = h.OriginX, h.OriginY
+	if !validCoordinate(int64(s.OriginX)) || !validCoordinate(int64(s.OriginY)) {
+		return s, fmt.Errorf("snapshot: invalid window origin %d,%d", s.OriginX, s.OriginY)
+	}
+
+	if !binaryFormat {
+		if len(h.Rows) != s.Options.Height {
+			return s, fmt.Errorf("snapshot: %d rows, expected %d", len(h.Rows), s.Options.Height)
+		}
+		for y, row := range h.Rows {
+			if len(row) != s.Options.Width {
+				return s, fmt.Errorf("snapshot: row %d: %d cells, expected %d", y, len(row), s.Options.Width)
+			}
+		}
+		s.Area = createArea(s.Options.Width, s.Options.Height)
+		for y, row := range h.Rows {
+			for x, c := range row {
+				switch c {
+				case 'O':
+					s.Area.Entities[y][x] = true
+				case '.':
+				default:
+					return s, fmt.Errorf("snapshot: row %d: unexpected symbol %q", y, c)
+				}
+			}
+		}
+		for _, c := range h.Outside {
+			if len(c) != 2 || !validCoordinate(int64(c[0])) || !validCoordinate(int64(c[1])) {
+				return s, fmt.Errorf("snapshot: invalid outside cell %v", c)
+			}
+		}
+		s.Outside = h.Outside
+		return s, nil
+	}
+
+	s.Area = createArea(s.Options.Width, s.Options.Height)
+	fr := flate.NewReader(br)
+	defer fr.Close()
+	row := make([]byte, (s.Area.Width+7)/8)
+	for y := range s.Area.Entities {
+		if _, err = io.ReadFull(fr, row); err != nil {
+			return s, fmt.Errorf("snapshot: the cells data: %v", err)
+		}
+		for x := range s.Area.Entities[y] {
+			s.Area.Entities[y][x] = row[x/8]&(1<<uint(x%8)) != 0
+		}
+	}
+	if h.Version < 2 {
+		return s, nil
+	}
+	cr := bufio.NewReader(fr)
+	n, err := binary.ReadUvarint(cr)
+	if err != nil || n > snapshotMaxCells {
+		return s, errors.New("snapshot: invalid outside cells")
+	}
+	for i := uint64(0); i < n; i++ {
+		x, errX := binary.ReadVarint(cr)
+		y, errY := binary.ReadVarint(cr)
+		if errX != nil || errY != nil || !validCoordinate(x) || !validCoordinate(y) {
+			return s, errors.New("snapshot: invalid outside cells")
+		}
+		s.Outside = append(s.Outside, []int{int(x), int(y)})
+	}
+	return s, nil
+}
+
+//validCoordinate reports whether the coordinate of the cell outside the field is in the supported range
+func validCoordinate(c int64) bool {
+	return c > -snapshotMaxCoordinate && c < snapshotMaxCoordinate
+}
+
+//options converts the serialized options
+func (so snapshotOptions) options() (o Options, err error) {
+	if so.Width < 1 || so.Height < 1 || so.Width > snapshotMaxCells/so.Height {
+		return o, fmt.Errorf("invalid field size %dx%d", so.Width, so.Height)
+	}
+	o = Options{
+		Width:           so.Width,
+		Height:          so.Height,
+		MaxSteps:        so.MaxSteps,
+		MaxSkippedTicks: so.MaxSkippedTicks,
+		CycleHistory:    so.CycleHistory,
+		History:         so.History,
+	}
+	if o.Interval, err = time.ParseDuration(so.Interval); err != nil {
+		return o, err
+	}
+	if o.Rule, err = ParseRule(so.Rule); err != nil {
+		return o, err
+	}
+	if o.Topology, err = ParseTopology(so.Topology); err != nil {
+		return o, err
+	}
+	return o, nil
+}
+
+//advancedFromJSON converts the whole numbers decoded from JSON back to int, the engines read the int options
+func advancedFromJSON(a map[string]interface{}) map[string]interface{} {
+	advanced := make(map[string]interface{}, len(a))
+	for k, v := range a {
+		if f, ok := v.(float64); ok && f == float64(int(f)) {
+			v = int(f)
+		}
+		advanced[k] = v
+	}
+	return advanced
+}
+
+//SaveSnapshot writes the snapshot of the universe state, the state is taken between the steps
+func (u *BaseUniverse) SaveSnapshot(w io.Writer) error {
+	done := make(chan Snapshot)
+	u.controlCh <- func() {
+		s := Snapshot{Options: u.options, IterationNum: u.state.IterationNum}
+		u.area.Lock()
+		s.Area = createArea(u.area.Width, u.area.Height)
+		for y := range u.area.Entities {
+			copy(s.Area.Entities[y], u.area.Entities[y])
+		}
+		if u.fieldCells != nil {
+			s.OriginX, s.OriginY, s.Outside = u.fieldCells()
+		}
+		u.area.Unlock()
+		done <- s
+	}
+	return WriteSnapshot(w, <-done)
+}
+
+//LoadSnapshot restores the universe state from the snapshot, the running simulation is stopped
+//the snapshot field size, rule and topology must match the universe options,
+//the snapshot with the cells outside the window can be loaded by the unbounded engines only
+//the Status struct will be written to the stateCh on finish
+func (u *BaseUniverse) LoadSnapshot(r io.Reader) error {
+	s, err := ReadSnapshot(r)
+	if err != nil {
+		return err
+	}
+	o := s.Options
+	if o.Width != u.options.Width || o.Height != u.options.Height || o.Rule != u.options.Rule || o.Topology != u.options.Topology {
+		return fmt.Errorf("snapshot: the field %dx%d %v %v doesn't match the universe %dx%d %v %v",
+			o.Width, o.Height, o.Rule, o.Topology, u.options.Width, u.options.Height, u.options.Rule, u.options.Topology)
+	}
+	if len(s.Outside) > 0 && u.settleField == nil {
+		return fmt.Errorf("snapshot: %d live cells outside the field, the engine with the unbounded field is required", len(s.Outside))
+	}
+	done := make(chan bool)
+	u.controlCh <- func() {
+		u.stop()
+		u.state.Lock()
+		u.area.Lock()
+		if u.onClear != nil {
+			u.onClear()
+		}
+		liveCells := 0
+		for y := range u.area.Entities {
+			copy(u.area.Entities[y], s.Area.Entities[y])
+			for _, e := range s.Area.Entities[y] {
+				if e {
+					liveCells++
+				}
+			}
+		}
+		if u.settleField != nil {
+			liveCells = u.settleField(s.OriginX, s.OriginY, s.Outside)
+		}
+		u.state.IterationNum = s.IterationNum
+		u.state.LiveCells = liveCells
+		u.state.FinishReason = FinishReasonNone
+		u.state.Period = 0
+		u.state.Err = nil
+		u.areaModified(false)
+		u.area.Unlock()
+		u.state.Unlock()
+		u.switchRunningState(RunningStateManual)
+		u.refreshView()
+		close(done)
+	}
+	<-done
+	return nil
+}
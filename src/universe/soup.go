@@ -0,0 +1,164 @@
+package universe
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+/*
+	The random soups
+	the soup is the random cells in the region of the field generated from the seed, so the soup can be reproduced exactly
+	the symmetric soups (like the apgsearch ones) are generated for the fundamental domain and mirrored:
+	C2 - 180 degree rotation, C4 - 90 degree rotation, D8 - rotations and reflections,
+	the C4 and D8 soups are square, the region is reduced to the square
+*/
+
+const DefSoupDensity = 0.5 //default probability of the live cell
+
+//Symmetry represents the symmetry of the soup
+type Symmetry int
+
+const (
+	SymmetryC1 Symmetry = iota //no symmetry
+	SymmetryC2                 //180 degree rotation
+	SymmetryC4                 //90 degree rotation
+	SymmetryD8                 //90 degree rotation and reflection
+)
+
+var symmetryNames = map[Symmetry]string{
+	SymmetryC1: "C1",
+	SymmetryC2: "C2",
+	SymmetryC4: "C4",
+	SymmetryD8: "D8",
+}
+
+//Soup is the random soup configuration
+type Soup struct {
+	Seed     int64   //random generator seed, 0 - the seed is chosen randomly
+	Density  float64 //the probability of the live cell in range (0, 1]
+	X        int     //the region to fill, the whole field if the width or the height is 0
+	Y        int
+	Width    int
+	Height   int
+	Symmetry Symmetry
+}
+
+//SymmetryNames returns the names of all supported symmetries
+func SymmetryNames() []string {
+	names := make([]string, 0, len(symmetryNames))
+	for s := SymmetryC1; s <= SymmetryD8; s++ {
+		names = append(names, symmetryNames[s])
+	}
+	return names
+}
+
+//ParseSymmetry returns the symmetry by its name
+func ParseSymmetry(s string) (Symmetry, error) {
+	name := strings.ToUpper(strings.TrimSpace(s))
+	for sym, n := range symmetryNames {
+		if n == name {
+			return sym, nil
+		}
+	}
+	return SymmetryC1, fmt.Errorf("unknown symmetry %q, expected one of [%s]", s, strings.Join(SymmetryNames(), "|"))
+}
+
+//String returns the symmetry name
+func (s Symmetry) String() string {
+	if n, ok := symmetryNames[s]; ok {
+		return n
+	}
+	return fmt.Sprintf("Symmetry(%d)", int(s))
+}
+
+//Validate returns the error if the soup can't be generated
+func (s Soup) Validate() error {
+	if !(s.Density > 0 && s.Density <= 1) {
+		return fmt.Errorf("invalid soup density %v, expected the probability in range (0, 1]", s.Density)
+	}
+	return nil
+}
+
+//Region returns the soup region clipped to the field of size width x height
+func (s Soup) Region(width int, height int) (x int, y int, w int, h int) {
+	x, y, w, h = s.X, s.Y, s.Width, s.Height
+	if w <= 0 || h <= 0 {
+		x, y, w, h = 0, 0, width, height
+	}
+	if x < 0 {
+		w, x = w+x, 0
+	}
+	if y < 0 {
+		h, y = h+y, 0
+	}
+	if x+w > width {
+		w = width - x
+	}
+	if y+h > height {
+		h = height - y
+	}
+	if w < 0 || h < 0 {
+		w, h = 0, 0
+	}
+	if s.Symmetry == SymmetryC4 || s.Symmetry == SymmetryD8 {
+		if w < h {
+			h = w
+		} else {
+			w = h
+		}
+	}
+	return
+}
+
+//Cells generates the soup cells for the field of size width x height, the soup should be validated by Validate
+func (s Soup) Cells(width int, height int) [][]int {
+	x, y, w, h := s.Region(width, height)
+	rnd := rand.New(rand.NewSource(s.Seed))
+	decided := make([]bool, w*h)
+	cells := [][]int{}
+	for cy := 0; cy < h; cy++ {
+		for cx := 0; cx < w; cx++ {
+			if decided[cy*w+cx] {
+				continue
+			}
+			//all cells of the orbit get the same state
+			live := rnd.Float64() < s.Density
+			for _, p := range s.Symmetry.orbit(cx, cy, w, h) {
+				if decided[p.y*w+p.x] {
+					continue
+				}
+				decided[p.y*w+p.x] = true
+				if live {
+					cells = append(cells, []int{x + p.x, y + p.y})
+				}
+			}
+		}
+	}
+	return cells
+}
+
+//String returns the soup description
+func (s Soup) String() string {
+	region := "the whole field"
+	if s.Width > 0 && s.Height > 0 {
+		region = fmt.Sprintf("%v,%v %vx%v", s.X, s.Y, s.Width, s.Height)
+	}
+	return fmt.Sprintf("density %v, %v, %v", s.Density, s.Symmetry, region)
+}
+
+//orbit returns the images of the point x,y in the region of size w x h under the symmetry transformations
+//the region of C4 and D8 symmetries should be square
+func (s Symmetry) orbit(x int, y int, w int, h int) []point {
+	p := []point{{x, y}}
+	switch s {
+	case SymmetryC2:
+		p = append(p, point{w - 1 - x, h - 1 - y})
+	case SymmetryC4:
+		p = append(p, point{w - 1 - y, x}, point{w - 1 - x, h - 1 - y}, point{y, h - 1 - x})
+	case SymmetryD8:
+		p = append(p, point{w - 1 - y, x}, point{w - 1 - x, h - 1 - y}, point{y, h - 1 - x},
+			point{w - 1 - x, y}, point{x, h - 1 - y}, point{y, x}, point{w - 1 - y, h - 1 - x})
+	}
+	return p
+}
@@ -0,0 +1,267 @@
+package universe
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+	Universe implementation storing the live cells only
+	the field is unbounded, the live cells are stored in the hash set by their coordinates
+	the neighbours are counted only around the live cells, so the calculation time depends on the population, not on the field size
+	the universe area is the window onto the field, it follows the live region when the pattern moves outside the window
+	the rules with B0 (the empty space must stay empty) and the topologies (the field has no edges) are not supported,
+	NewSparseUniverse returns the error on them
+*/
+
+type point struct {
+	x int
+	y int
+}
+
+type SparseUniverse struct {
+	*BaseUniverse
+	cells      map[point]struct{} //live cells
+	next       map[point]struct{} //the next generation buffer
+	neighbours map[point]int      //neighbours counters buffer
+	originX    int                //window position: field coordinates of the area 0,0 cell
+	originY    int
+	bounds     [4]int  //the bounding box of the live cells: x1, y1, x2, y2
+	rendered   []point //the live cells of the area, cleared by the next rendering instead of the whole area
+}
+
+//sparseField is the field state recorded to the undo history, the cells inside the window are recorded by the area
+type sparseField struct {
+	originX int
+	originY int
+	cells   []point //the live cells outside the window
+}
+
+//NewSparseUniverse creates the sparse universe, returns the error if the rule or the topology isn't supported
+func NewSparseUniverse(o *Options, stateCh chan Status) (Universe, error) {
+	if err := checkUnboundedOptions("sparse", o); err != nil {
+		return nil, err
+	}
+	su := SparseUniverse{BaseUniverse: NewBaseUniverse(o, stateCh)}
+	//redefine the nextIteration
+	su.BaseUniverse.nextIteration = su.nextIteration
+	su.BaseUniverse.onClear = su.reset
+	su.BaseUniverse.stateHash = su.stateHash
+	su.BaseUniverse.fieldState = su.fieldState
+	su.BaseUniverse.restoreField = su.restoreField
+	su.BaseUniverse.fieldCells = su.fieldCells
+	su.BaseUniverse.settleField = su.settleField
+	su.BaseUniverse.windowOrigin = func() (int, int) {
+		return su.originX, su.originY
+	}
+	su.next = make(map[point]struct{})
+	su.neighbours = make(map[point]int)
+	su.reset()
+	su.options.Advanced["engine"] = "sparse"
+	su.options.Advanced["Field"] = "unbounded"
+	return &su, nil
+}
+
+//nextIteration calculates the next generation of the live cells set and renders the window to the area
+func (su *SparseUniverse) nextIteration() (hasLiveEnitities bool, changed bool) {
+	su.area.Lock()
+	defer su.area.Unlock()
+	start := time.Now()
+	if su.area.modified {
+		su.importArea()
+		su.area.modified = false
+	}
+
+	for p := range su.neighbours {
+		delete(su.neighbours, p)
+	}
+	for p := range su.next {
+		delete(su.next, p)
+	}
+	for p := range su.cells {
+		for i := -1; i < 2; i++ {
+			for j := -1; j < 2; j++ {
+				if i != 0 || j != 0 {
+					su.neighbours[point{p.x + i, p.y + j}]++
+				}
+			}
+		}
+	}
+	rule := su.options.Rule
+	for p, n := range su.neighbours {
+		_, alive := su.cells[p]
+		nextState := rule.NextState(alive, n)
+		if nextState {
+			su.next[p] = struct{}{}
+		}
+		changed = changed || nextState != alive
+	}
+	//the live cells without neighbours
+	for p := range su.cells {
+		if _, ok := su.neighbours[p]; !ok {
+			if rule.Survival(0) {
+				su.next[p] = struct{}{}
+			} else {
+				changed = true
+			}
+		}
+	}
+	su.cells, su.next = su.next, su.cells
+
+	su.updateBounds()
+	su.followLiveRegion()
+	su.renderArea()
+
+	su.state.LiveCells = len(su.cells)
+	su.state.IterationTime = time.Since(start)
+	su.state.Details = su.details()
+	hasLiveEnitities = len(su.cells) > 0
+	return
+}
+
+//reset kills all cells and moves the window to the initial position
+func (su *SparseUniverse) reset() {
+	su.cells = make(map[point]struct{})
+	su.originX, su.originY = 0, 0
+	su.bounds = [4]int{}
+	su.state.Details = su.details()
+}
+
+//fieldState returns the window position and the live cells outside the window
+func (su *SparseUniverse) fieldState() interface{} {
+	f := sparseField{originX: su.originX, originY: su.originY}
+	for p := range su.cells {
+		if !su.inWindow(p) {
+			f.cells = append(f.cells, p)
+		}
+	}
+	return f
+}
+
+//restoreField restores the window position and the cells outside the window, the cells inside the window are taken from the area
+func (su *SparseUniverse) restoreField(field interface{}) (liveCells int) {
+	su.reset()
+	if f, ok := field.(sparseField); ok {
+		su.originX, su.originY = f.originX, f.originY
+		for _, p := range f.cells {
+			su.cells[p] = struct{}{}
+		}
+	}
+	su.importArea()
+	su.updateBounds()
+	su.state.Details = su.details()
+	return len(su.cells)
+}
+
+//fieldCells returns the window position and the live cells outside the window saved to the snapshot
+func (su *SparseUniverse) fieldCells() (originX int, originY int, outside [][]int) {
+	f := su.fieldState().(sparseField)
+	for _, p := range f.cells {
+		outside = append(outside, []int{p.x, p.y})
+	}
+	return f.originX, f.originY, outside
+}
+
+//settleField moves the window and settles the cells outside the window loaded from the snapshot
+func (su *SparseUniverse) settleField(originX int, originY int, outside [][]int) (liveCells int) {
+	f := sparseField{originX: originX, originY: originY}
+	for _, c := range outside {
+		f.cells = append(f.cells, point{c[0], c[1]})
+	}
+	return su.restoreField(f)
+}
+
+//stateHash calculates the hash of the live cells set, the hash doesn't depend on the cells order
+func (su *SparseUniverse) stateHash() uint64 {
+	h := uint64(len(su.cells))
+	for p := range su.cells {
+		h += hashMix(uint64(p.x), uint64(p.y))
+	}
+	return h
+}
+
+//details returns the engine statistics
+func (su *SparseUniverse) details() map[string]interface{} {
+	d := map[string]interface{}{
+		"Window origin": fmt.Sprintf("%v,%v", su.originX, su.originY),
+	}
+	if len(su.cells) > 0 {
+		d["Bounding box"] = fmt.Sprintf("%v,%v - %v,%v", su.bounds[0], su.bounds[1], su.bounds[2], su.bounds[3])
+	}
+	return d
+}
+
+//importArea replaces the cells inside the window with the area cells (settled or edited)
+func (su *SparseUniverse) importArea() {
+	for p := range su.cells {
+		if su.inWindow(p) {
+			delete(su.cells, p)
+		}
+	}
+	su.rendered = su.rendered[:0]
+	for y, row := range su.area.Entities {
+		for x, e := range row {
+			if e {
+				su.cells[point{x + su.originX, y + su.originY}] = struct{}{}
+				su.rendered = append(su.rendered, point{x, y})
+			}
+		}
+	}
+}
+
+//updateBounds calculates the bounding box of the live cells
+func (su *SparseUniverse) updateBounds() {
+	first := true
+	for p := range su.cells {
+		if first {
+			su.bounds = [4]int{p.x, p.y, p.x, p.y}
+			first = false
+			continue
+		}
+		if p.x < su.bounds[0] {
+			su.bounds[0] = p.x
+		}
+		if p.y < su.bounds[1] {
+			su.bounds[1] = p.y
+		}
+		if p.x > su.bounds[2] {
+			su.bounds[2] = p.x
+		}
+		if p.y > su.bounds[3] {
+			su.bounds[3] = p.y
+		}
+	}
+}
+
+//followLiveRegion moves the window to the centre of the live region if the region is not inside the window
+func (su *SparseUniverse) followLiveRegion() {
+	if len(su.cells) == 0 {
+		return
+	}
+	if su.inWindow(point{su.bounds[0], su.bounds[1]}) && su.inWindow(point{su.bounds[2], su.bounds[3]}) {
+		return
+	}
+	su.originX = (su.bounds[0]+su.bounds[2])/2 - su.area.Width/2
+	su.originY = (su.bounds[1]+su.bounds[3])/2 - su.area.Height/2
+}
+
+//renderArea renders the window onto the field to the area
+//only the previously rendered cells are cleared, so the rendering time depends on the population, not on the window size
+func (su *SparseUniverse) renderArea() {
+	for _, p := range su.rendered {
+		su.area.Entities[p.y][p.x] = false
+	}
+	su.rendered = su.rendered[:0]
+	for p := range su.cells {
+		if su.inWindow(p) {
+			x, y := p.x-su.originX, p.y-su.originY
+			su.area.Entities[y][x] = true
+			su.rendered = append(su.rendered, point{x, y})
+		}
+	}
+}
+
+//inWindow reports whether the point is inside the window
+func (su *SparseUniverse) inWindow(p point) bool {
+	return p.x >= su.originX && p.y >= su.originY && p.x < su.originX+su.area.Width && p.y < su.originY+su.area.Height
+}
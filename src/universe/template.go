@@ -0,0 +1,85 @@
+package universe
+
+import "fmt"
+
+/*
+	The template placement
+	the template bounding box is flipped, rotated and placed at the position on the field,
+	so the same template can be settled several times in different orientations (the collision experiments)
+*/
+
+//Placement describes how to place the template on the field
+type Placement struct {
+	X        int //the position of the template bounding box top-left corner (or its centre if Center is set)
+	Y        int
+	Rotation int  //clockwise rotation in degrees: 0, 90, 180 or 270, applied after the flips
+	FlipH    bool //mirror horizontally (left-right)
+	FlipV    bool //mirror vertically (top-bottom)
+	Center   bool //X,Y is the position of the template bounding box centre
+}
+
+//Transform returns the template with the coordinates flipped, rotated and moved according to the placement
+func (t Template) Transform(p Placement) (Template, error) {
+	if p.Rotation%90 != 0 {
+		return t, fmt.Errorf("invalid rotation %v, expected 0, 90, 180 or 270 degrees", p.Rotation)
+	}
+	out := t
+	out.Coordinates = make([][]int, 0, len(t.Coordinates))
+	if len(t.Coordinates) == 0 {
+		return out, nil
+	}
+	minX, minY, maxX, maxY := t.bounds()
+	w, h := maxX-minX+1, maxY-minY+1
+	rotation := (p.Rotation%360 + 360) % 360
+	rw, rh := w, h
+	if rotation == 90 || rotation == 270 {
+		rw, rh = h, w
+	}
+	ox, oy := p.X, p.Y
+	if p.Center {
+		ox, oy = p.X-rw/2, p.Y-rh/2
+	}
+	for _, c := range t.Coordinates {
+		x, y := c[0]-minX, c[1]-minY
+		if p.FlipH {
+			x = w - 1 - x
+		}
+		if p.FlipV {
+			y = h - 1 - y
+		}
+		switch rotation {
+		case 90:
+			x, y = h-1-y, x
+		case 180:
+			x, y = w-1-x, h-1-y
+		case 270:
+			x, y = y, w-1-x
+		}
+		out.Coordinates = append(out.Coordinates, []int{x + ox, y + oy})
+	}
+	return out, nil
+}
+
+//bounds returns the bounding box of the template coordinates
+func (t Template) bounds() (minX int, minY int, maxX int, maxY int) {
+	if len(t.Coordinates) == 0 {
+		return
+	}
+	minX, minY = t.Coordinates[0][0], t.Coordinates[0][1]
+	maxX, maxY = minX, minY
+	for _, c := range t.Coordinates {
+		if c[0] < minX {
+			minX = c[0]
+		}
+		if c[0] > maxX {
+			maxX = c[0]
+		}
+		if c[1] < minY {
+			minY = c[1]
+		}
+		if c[1] > maxY {
+			maxY = c[1]
+		}
+	}
+	return
+}
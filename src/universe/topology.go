@@ -0,0 +1,86 @@
+package universe
+
+import (
+	"fmt"
+	"strings"
+)
+
+//Topology represents the way the field edges are glued together
+type Topology int
+
+const (
+	TopologyBounded      Topology = iota //cells outside the field are always dead
+	TopologyTorus                        //opposite edges are glued together
+	TopologyKleinBottle                  //left and right edges are glued together, top and bottom edges are glued with a twist
+	TopologyCrossSurface                 //both pairs of the opposite edges are glued with a twist (the real projective plane)
+)
+
+var topologyNames = map[Topology]string{
+	TopologyBounded:      "bounded",
+	TopologyTorus:        "torus",
+	TopologyKleinBottle:  "klein",
+	TopologyCrossSurface: "cross",
+}
+
+//TopologyNames returns the names of all supported topologies
+func TopologyNames() []string {
+	names := make([]string, 0, len(topologyNames))
+	for t := TopologyBounded; t <= TopologyCrossSurface; t++ {
+		names = append(names, topologyNames[t])
+	}
+	return names
+}
+
+//ParseTopology returns the topology by its name
+func ParseTopology(s string) (Topology, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for t, n := range topologyNames {
+		if n == name {
+			return t, nil
+		}
+	}
+	return TopologyBounded, fmt.Errorf("unknown topology %q, expected one of [%s]", s, strings.Join(TopologyNames(), "|"))
+}
+
+//String returns the topology name
+func (t Topology) String() string {
+	if n, ok := topologyNames[t]; ok {
+		return n
+	}
+	return fmt.Sprintf("Topology(%d)", int(t))
+}
+
+//Wrap maps the point x,y, which can be outside the field of size width x height, to the point inside the field
+//returns ok == false if the point is outside the bounded field
+func (t Topology) Wrap(x int, y int, width int, height int) (wx int, wy int, ok bool) {
+	if x >= 0 && y >= 0 && x < width && y < height {
+		return x, y, true
+	}
+	switch t {
+	case TopologyTorus:
+		return mod(x, width), mod(y, height), true
+	case TopologyKleinBottle:
+		if y < 0 || y >= height {
+			x = width - 1 - x
+		}
+		return mod(x, width), mod(y, height), true
+	case TopologyCrossSurface:
+		if x < 0 || x >= width {
+			y = height - 1 - y
+		}
+		if y < 0 || y >= height {
+			x = width - 1 - x
+		}
+		return mod(x, width), mod(y, height), true
+	}
+	return x, y, false
+}
+
+//mod returns the non-negative remainder of a / b
+func mod(a int, b int) int {
+	a %= b
+	if a < 0 {
+		a += b
+	}
+	return a
+}
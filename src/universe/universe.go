@@ -1,20 +1,31 @@
 package universe
 
+import "io"
+
 //Universe represent the unified Universal interface
 type Universe interface {
 	Status() Status
 	Options() Options
 	Area() Area
+	Activity() *Activity
 	StateCh() chan Status
 	AddTemplate(tmpl Template)
+	Templates() []Template
 	SettleTemplate(name string)
-	SettleWithRandomData()
+	SettleTemplateAt(name string, p Placement) error
+	SettleWithRandomData() error
 	Settle(vc [][]int)
 	InverseCell(x int, y int)
+	Edit(e Edit)
 	RegisterViewer(v Viewer)
 	Run()
 	Stop()
 	Step()
 	Clear()
+	Undo()
+	Redo()
+	Rewind(n int)
+	SaveSnapshot(w io.Writer) error
+	LoadSnapshot(r io.Reader) error
 	Close()
 }
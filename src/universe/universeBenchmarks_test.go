@@ -6,7 +6,8 @@ import (
 )
 
 var (
-	testTemplate = Template{"ts1", "", [][]int{{1, 1}, {1, 2}, {2, 1}, {2, 2}, {3, 3}, {4, 2}, {4, 3}, {5, 3}}}
+	testTemplate   = Template{Name: "ts1", Coordinates: [][]int{{1, 1}, {1, 2}, {2, 1}, {2, 2}, {3, 3}, {4, 2}, {4, 3}, {5, 3}}}
+	gliderTemplate = Template{Name: "glider", Coordinates: [][]int{{21, 20}, {22, 21}, {20, 22}, {21, 22}, {22, 22}}}
 
 	engines = map[string]func(o *Options, stateCh chan Status) Universe{
 		"base": func(o *Options, stateCh chan Status) Universe {
@@ -15,12 +16,37 @@ var (
 		"simple":        NewSimpleUniverse,
 		"smallBuff":     NewSmallBuffUniverse,
 		"multithreaded": NewMultithreadedUniverse,
+		"bitboard":      NewBitboardUniverse,
+		"hashlife":      unbounded(NewHashLifeUniverse),
+		"sparse":        unbounded(NewSparseUniverse),
+		"activeTiles":   NewActiveTilesUniverse,
+	}
+
+	//engines with the unbounded field, the rules with B0 and the topologies are not supported
+	unboundedEngines = map[string]bool{
+		"hashlife": true,
+		"sparse":   true,
 	}
 )
 
+//unbounded adapts the constructor of the unbounded engine to the engines map
+//the tests skip the unbounded engines for the options they reject, so the error is unexpected
+func unbounded(newUniverse func(o *Options, stateCh chan Status) (Universe, error)) func(o *Options, stateCh chan Status) Universe {
+	return func(o *Options, stateCh chan Status) Universe {
+		u, err := newUniverse(o, stateCh)
+		if err != nil {
+			panic(err)
+		}
+		return u
+	}
+}
+
 const (
 	width  = 200
 	height = 200
+
+	sparseWidth  = 2000
+	sparseHeight = 2000
 )
 
 func universeStep(u Universe, b *testing.B) {
@@ -71,6 +97,28 @@ func universeRun(u Universe, b *testing.B) {
 	close(stateCh)
 }
 
+//universeSteps measures the steps of the running simulation without the resetting between the steps
+func universeSteps(u Universe, b *testing.B) {
+	u.AddTemplate(testTemplate)
+	u.AddTemplate(gliderTemplate)
+	stateCh := u.StateCh()
+	u.SettleTemplate("ts1")
+	u.SettleTemplate("glider")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		u.Step()
+		for {
+			st := <-stateCh
+			if st.RunningMode != RunningStateStep {
+				break
+			}
+		}
+	}
+	u.Close()
+	close(stateCh)
+}
+
 func newStateCh() chan Status {
 	return make(chan Status, 10)
 }
@@ -80,6 +128,8 @@ func newUniverseOptions() *Options {
 	o.Interval = 0
 	o.Width = width
 	o.Height = height
+	o.CycleHistory = 0
+	o.History = 0
 	return &o
 }
 
@@ -109,3 +159,17 @@ func Benchmark_Universe(b *testing.B) {
 		})
 	}
 }
+
+//Benchmark_SparseField measures the steps on the large field with a few active patterns
+func Benchmark_SparseField(b *testing.B) {
+	for _, e := range engineNames() {
+		b.Run(e, func(b *testing.B) {
+			o := newUniverseOptions()
+			o.Width = sparseWidth
+			o.Height = sparseHeight
+			o.MaxSteps = 0
+			u := engines[e](o, newStateCh())
+			universeSteps(u, b)
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package universe
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/*
+	The fuzz tests
+	the random fields (dimensions, rule, topology, cells) are run through every engine from the engines map,
+	the results are compared with the straightforward reference implementation
+	run with: go test ./src/universe -run none -fuzz Fuzz_Engines
+*/
+
+const (
+	fuzzMaxFieldSize = 48
+	fuzzMaxSteps     = 24
+)
+
+func Fuzz_Engines(f *testing.F) {
+	f.Add(uint8(1), uint8(1), int64(1), uint8(255), uint8(0), uint16(1<<3), uint16(1<<2|1<<3), uint8(3))
+	f.Add(uint8(1), uint8(7), int64(2), uint8(128), uint8(1), uint16(1<<3), uint16(1<<2|1<<3), uint8(8))
+	f.Add(uint8(5), uint8(2), int64(3), uint8(100), uint8(2), uint16(1<<3|1<<6), uint16(1<<2|1<<3), uint8(10))
+	f.Add(uint8(33), uint8(20), int64(4), uint8(90), uint8(3), uint16(1<<3), uint16(1<<2|1<<3), uint8(20))
+	f.Add(uint8(47), uint8(47), int64(5), uint8(80), uint8(0), uint16(1<<2), uint16(0), uint8(20))
+	f.Add(uint8(16), uint8(16), int64(6), uint8(60), uint8(1), uint16(1<<0|1<<3), uint16(1<<0|1<<8), uint8(12))
+	f.Fuzz(func(t *testing.T, width uint8, height uint8, seed int64, density uint8, topology uint8, birth uint16, survival uint16, steps uint8) {
+		o := testOptions(fuzzRule(birth, survival), Topology(int(topology)%len(topologyNames)))
+		o.Width = 1 + int(width)%fuzzMaxFieldSize
+		o.Height = 1 + int(height)%fuzzMaxFieldSize
+		cells := fuzzCells(seed, density, o.Width, o.Height)
+		n := 1 + int(steps)%fuzzMaxSteps
+		for _, e := range engineNames() {
+			runReference(t, e, o, birth, survival, cells, n)
+		}
+	})
+}
+
+//fuzzRule returns the rule with the birth and survival neighbours counts from the bit masks
+func fuzzRule(birth uint16, survival uint16) Rule {
+	var b, s strings.Builder
+	for n := 0; n <= 8; n++ {
+		if birth&(1<<uint(n)) != 0 {
+			b.WriteByte(byte('0' + n))
+		}
+		if survival&(1<<uint(n)) != 0 {
+			s.WriteByte(byte('0' + n))
+		}
+	}
+	return MustParseRule("B" + b.String() + "/S" + s.String())
+}
+
+//fuzzCells returns the random cells, some of them are outside the field and must be ignored
+func fuzzCells(seed int64, density uint8, width int, height int) [][]int {
+	rnd := rand.New(rand.NewSource(seed))
+	cells := [][]int{}
+	for i := 0; i < width*height*int(density)/255; i++ {
+		cells = append(cells, []int{rnd.Intn(width+4) - 2, rnd.Intn(height+4) - 2})
+	}
+	return cells
+}
+
+//runReference runs the engine and compares the window and the population with the reference implementation after each step
+//the reference gets the rule as the birth and survival bit masks, the same the rule of the options is created from
+func runReference(t *testing.T, engine string, o *Options, birth uint16, survival uint16, cells [][]int, steps int) {
+	t.Helper()
+	unbounded := unboundedEngines[engine]
+	if unbounded && (birth&1 != 0 || o.Topology != TopologyBounded) {
+		//the unbounded engines don't support B0 rules and the wrapped topologies
+		return
+	}
+	stateCh := newStateCh()
+	u := engines[engine](o, stateCh)
+	defer u.Close()
+	ref := newReferenceField(o, birth, survival, unbounded)
+	for _, c := range cells {
+		if c[0] >= 0 && c[1] >= 0 && c[0] < o.Width && c[1] < o.Height {
+			ref.cells[point{c[0], c[1]}] = true
+		}
+	}
+	u.Settle(cells)
+	for step := 0; ; step++ {
+		st := u.Status()
+		originX, originY := 0, 0
+		if origin, ok := st.Details["Window origin"]; ok {
+			if _, err := fmt.Sscanf(fmt.Sprint(origin), "%d,%d", &originX, &originY); err != nil {
+				t.Fatalf("%s: invalid window origin %v", engine, origin)
+			}
+		}
+		if expected := ref.window(originX, originY); !reflect.DeepEqual(areaCells(u.Area()), expected) {
+			t.Fatalf("%s: %s %s %dx%d step %d: the area %v, expected %v",
+				engine, o.Rule, o.Topology, o.Width, o.Height, step, areaCells(u.Area()), expected)
+		}
+		if step > 0 && st.LiveCells != len(ref.cells) {
+			t.Fatalf("%s: %s %s %dx%d step %d: %d live cells, expected %d",
+				engine, o.Rule, o.Topology, o.Width, o.Height, step, st.LiveCells, len(ref.cells))
+		}
+		if step == steps {
+			break
+		}
+		stepAndWait(u, stateCh)
+		ref.step()
+	}
+}
+
+//referenceField is the straightforward implementation of the universe used as the reference for the fuzz tests
+//it shares no code with the engines: the rule is checked by the bit masks and the bounded field
+//is surrounded by the ghost cells copied from the glued edges
+type referenceField struct {
+	cells     map[point]bool
+	width     int
+	height    int
+	birth     uint16 //the bit n is set if the dead cell with n neighbours becomes alive
+	survival  uint16 //the bit n is set if the live cell with n neighbours stays alive
+	topology  Topology
+	unbounded bool
+}
+
+func newReferenceField(o *Options, birth uint16, survival uint16, unbounded bool) *referenceField {
+	return &referenceField{
+		cells:     map[point]bool{},
+		width:     o.Width,
+		height:    o.Height,
+		birth:     birth,
+		survival:  survival,
+		topology:  o.Topology,
+		unbounded: unbounded,
+	}
+}
+
+//step calculates the next generation checking every cell of the field (of the live cells neighbourhood if unbounded)
+func (r *referenceField) step() {
+	alive := func(p point) bool { return r.cells[p] }
+	var candidates []point
+	if r.unbounded {
+		seen := map[point]bool{}
+		for p := range r.cells {
+			for dy := -1; dy < 2; dy++ {
+				for dx := -1; dx < 2; dx++ {
+					c := point{p.x + dx, p.y + dy}
+					if !seen[c] {
+						seen[c] = true
+						candidates = append(candidates, c)
+					}
+				}
+			}
+		}
+	} else {
+		ghosts := r.ghosts()
+		alive = func(p point) bool { return ghosts[p.y+1][p.x+1] }
+		for y := 0; y < r.height; y++ {
+			for x := 0; x < r.width; x++ {
+				candidates = append(candidates, point{x, y})
+			}
+		}
+	}
+	next := map[point]bool{}
+	for _, p := range candidates {
+		n := 0
+		for dy := -1; dy < 2; dy++ {
+			for dx := -1; dx < 2; dx++ {
+				if (dx != 0 || dy != 0) && alive(point{p.x + dx, p.y + dy}) {
+					n++
+				}
+			}
+		}
+		mask := r.birth
+		if r.cells[p] {
+			mask = r.survival
+		}
+		if mask&(1<<uint(n)) != 0 {
+			next[p] = true
+		}
+	}
+	r.cells = next
+}
+
+//ghosts returns the field surrounded by the ghost cells, the cell x,y is at [y+1][x+1]
+//the ghost columns are copied from the opposite edge (upside down if the edges are twisted),
+//then the ghost rows are copied the same way including the ghost columns, so the corners are glued through both edges
+func (r *referenceField) ghosts() [][]bool {
+	w, h := r.width, r.height
+	g := make([][]bool, h+2)
+	for y := range g {
+		g[y] = make([]bool, w+2)
+	}
+	for p := range r.cells {
+		g[p.y+1][p.x+1] = true
+	}
+	if r.topology == TopologyBounded {
+		return g
+	}
+	for y := 1; y <= h; y++ {
+		sy := y
+		if r.topology == TopologyCrossSurface {
+			sy = h + 1 - y
+		}
+		g[y][0] = g[sy][w]
+		g[y][w+1] = g[sy][1]
+	}
+	for x := 0; x <= w+1; x++ {
+		sx := x
+		if r.topology != TopologyTorus {
+			sx = w + 1 - x
+		}
+		g[0][x] = g[h][sx]
+		g[h+1][x] = g[1][sx]
+	}
+	return g
+}
+
+//window returns the live cells inside the field window with the origin in the scan order
+func (r *referenceField) window(originX int, originY int) [][]int {
+	cells := [][]int{}
+	for y := 0; y < r.height; y++ {
+		for x := 0; x < r.width; x++ {
+			if r.cells[point{x + originX, y + originY}] {
+				cells = append(cells, []int{x, y})
+			}
+		}
+	}
+	return cells
+}
@@ -0,0 +1,916 @@
+package universe
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+/*
+	The differential correctness tests
+	the known patterns and the random soups are run through every engine from the engines map,
+	the area snapshots are compared with the golden expectations and with the reference engine
+*/
+
+const (
+	referenceEngine = "base"
+	testFieldSize   = 200
+)
+
+//testPattern is the pattern with the golden expectations
+type testPattern struct {
+	name  string
+	cells [][]int
+	steps int
+	//golden returns the expected live cells after the step or nil if the cells are not known
+	golden func(step int) [][]int
+	//population is the expected number of the live cells after the steps
+	population map[int]int
+}
+
+var (
+	blinker      = [][]int{{100, 100}, {101, 100}, {102, 100}}
+	glider       = [][]int{{101, 100}, {102, 101}, {100, 102}, {101, 102}, {102, 102}}
+	rPentomino   = [][]int{{101, 100}, {102, 100}, {100, 101}, {101, 101}, {101, 102}}
+	gosperGun    = [][]int{{24, 0}, {22, 1}, {24, 1}, {12, 2}, {13, 2}, {20, 2}, {21, 2}, {34, 2}, {35, 2}, {11, 3}, {15, 3}, {20, 3}, {21, 3}, {34, 3}, {35, 3}, {0, 4}, {1, 4}, {10, 4}, {16, 4}, {20, 4}, {21, 4}, {0, 5}, {1, 5}, {10, 5}, {14, 5}, {16, 5}, {17, 5}, {22, 5}, {24, 5}, {10, 6}, {16, 6}, {24, 6}, {11, 7}, {15, 7}, {12, 8}, {13, 8}}
+	testPatterns = []testPattern{
+		{
+			name:  "blinker",
+			cells: blinker,
+			steps: 10,
+			golden: func(step int) [][]int {
+				if step%2 == 1 {
+					return [][]int{{101, 99}, {101, 100}, {101, 101}}
+				}
+				return blinker
+			},
+		},
+		{
+			name:  "glider",
+			cells: glider,
+			steps: 40,
+			golden: func(step int) [][]int {
+				//the glider moves by one cell diagonally every 4 generations
+				if step%4 != 0 {
+					return nil
+				}
+				return shiftCells(glider, step/4, step/4)
+			},
+		},
+		{
+			name:       "R-pentomino",
+			cells:      rPentomino,
+			steps:      90,
+			population: map[int]int{30: 27, 60: 79, 90: 98},
+		},
+		{
+			name:       "Gosper glider gun",
+			cells:      shiftCells(gosperGun, 20, 20),
+			steps:      120,
+			population: map[int]int{30: 41, 60: 46, 90: 51, 120: 56},
+		},
+	}
+)
+
+func Test_Patterns(t *testing.T) {
+	for _, p := range testPatterns {
+		p := p
+		t.Run(p.name, func(t *testing.T) {
+			reference := runSnapshots(t, referenceEngine, testOptions(ConwayRule, TopologyBounded), p.cells, p.steps)
+			for _, e := range engineNames() {
+				snapshots := reference
+				if e != referenceEngine {
+					snapshots = runSnapshots(t, e, testOptions(ConwayRule, TopologyBounded), p.cells, p.steps)
+				}
+				for step, s := range snapshots {
+					if p.golden != nil {
+						if g := p.golden(step); g != nil && !reflect.DeepEqual(s.cells, sortCells(g)) {
+							t.Fatalf("%s: step %d: the cells %v, expected %v", e, step, s.cells, sortCells(g))
+						}
+					}
+					if n, ok := p.population[step]; ok && s.liveCells != n {
+						t.Fatalf("%s: step %d: %d live cells, expected %d", e, step, s.liveCells, n)
+					}
+					compareSnapshots(t, e, step, s, reference[step])
+				}
+			}
+		})
+	}
+}
+
+func Test_RandomSoups(t *testing.T) {
+	rules := []string{DefRule, "B36/S23", "B2/S", "B3678/S34678"}
+	for seed := int64(1); seed <= 3; seed++ {
+		//the soup is in the field centre, the active region can't reach the edges during the steps,
+		//so the bounded and the unbounded engines give the same results
+		cells := randomCells(seed, 80, 80, 40, 40)
+		for _, r := range rules {
+			o := testOptions(MustParseRule(r), TopologyBounded)
+			t.Run(fmt.Sprintf("%s/seed %d", r, seed), func(t *testing.T) {
+				compareEngines(t, o, cells, 30, false)
+			})
+		}
+	}
+}
+
+func Test_Topologies(t *testing.T) {
+	for _, tp := range []Topology{TopologyBounded, TopologyTorus, TopologyKleinBottle, TopologyCrossSurface} {
+		o := testOptions(ConwayRule, tp)
+		o.Width, o.Height = 70, 45
+		cells := randomCells(int64(tp)+1, 0, 0, o.Width, o.Height)
+		t.Run(tp.String(), func(t *testing.T) {
+			compareEngines(t, o, cells, 50, true)
+		})
+	}
+}
+
+func Test_Undo(t *testing.T) {
+	for _, e := range engineNames() {
+		t.Run(e, func(t *testing.T) {
+			o := testOptions(ConwayRule, TopologyBounded)
+			o.History = 5
+			stateCh := newStateCh()
+			u := engines[e](o, stateCh)
+			defer u.Close()
+			u.Settle(rPentomino)
+			states := [][][]int{areaCells(u.Area())}
+			for i := 0; i < 8; i++ {
+				stepAndWait(u, stateCh)
+				states = append(states, areaCells(u.Area()))
+			}
+			checkState := func(action string, iteration int, undo int, redo int) {
+				t.Helper()
+				st := u.Status()
+				if st.IterationNum != iteration || st.Undo != undo || st.Redo != redo {
+					t.Fatalf("%s: step %d, undo %d, redo %d, expected %d, %d, %d", action, st.IterationNum, st.Undo, st.Redo, iteration, undo, redo)
+				}
+				if !reflect.DeepEqual(areaCells(u.Area()), states[iteration]) {
+					t.Fatalf("%s: the area differs from the step %d", action, iteration)
+				}
+				if st.LiveCells != len(states[iteration]) {
+					t.Fatalf("%s: %d live cells, expected %d", action, st.LiveCells, len(states[iteration]))
+				}
+			}
+
+			u.Undo()
+			waitManual(stateCh)
+			checkState("undo", 7, 4, 1)
+			u.Rewind(10)
+			waitManual(stateCh)
+			checkState("rewind", 3, 0, 5)
+			u.Redo()
+			waitManual(stateCh)
+			checkState("redo", 4, 1, 4)
+			//the simulation continues from the restored state, the redo history is discarded
+			stepAndWait(u, stateCh)
+			checkState("step", 5, 2, 0)
+
+			u.InverseCell(0, 0)
+			u.Clear()
+			waitManual(stateCh)
+			if u.Status().LiveCells != 0 || u.Status().Undo != 4 {
+				t.Fatalf("clear: %d live cells, undo %d", u.Status().LiveCells, u.Status().Undo)
+			}
+			u.Undo()
+			waitManual(stateCh)
+			if cells := areaCells(u.Area()); len(cells) != len(states[5])+1 || !reflect.DeepEqual(cells[0], []int{0, 0}) {
+				t.Fatalf("undo clear: the area %v", cells)
+			}
+			u.Undo()
+			waitManual(stateCh)
+			checkState("undo inverse", 5, 2, 2)
+			stepAndWait(u, stateCh)
+			checkState("step after undo", 6, 3, 0)
+		})
+	}
+}
+
+func Test_UndoStroke(t *testing.T) {
+	for _, e := range engineNames() {
+		t.Run(e, func(t *testing.T) {
+			o := testOptions(ConwayRule, TopologyBounded)
+			o.History = 5
+			stateCh := newStateCh()
+			u := engines[e](o, stateCh)
+			defer u.Close()
+			block := [][]int{{1, 1}, {2, 1}, {1, 2}, {2, 2}}
+			u.Settle(block)
+			undo := u.Status().Undo
+			//the segments of one stroke are one undo step, the repeated cells don't break it
+			editAndWait(u, Edit{Live: [][]int{{10, 5}, {11, 5}}})
+			editAndWait(u, Edit{Live: [][]int{{11, 5}, {12, 5}}, Join: true})
+			editAndWait(u, Edit{Live: [][]int{{12, 5}, {12, 6}}, Join: true})
+			if n := u.Status().Undo; n != undo+1 {
+				t.Fatalf("stroke: undo %d, expected %d", n, undo+1)
+			}
+			u.Undo()
+			waitManual(stateCh)
+			if cells := areaCells(u.Area()); !reflect.DeepEqual(cells, block) {
+				t.Fatalf("undo stroke: the area %v", cells)
+			}
+			//the step between the segments splits the stroke
+			editAndWait(u, Edit{Live: [][]int{{10, 5}}})
+			stepAndWait(u, stateCh)
+			editAndWait(u, Edit{Live: [][]int{{10, 10}}, Join: true})
+			if n := u.Status().Undo; n != undo+3 {
+				t.Fatalf("stroke with the step: undo %d, expected %d", n, undo+3)
+			}
+		})
+	}
+}
+
+func Test_UndoOutsideWindow(t *testing.T) {
+	//the glider flies away from the block, so the unbounded engines keep the cells outside the window
+	//the bounded engines are checked by Test_Undo, the glider crashes into the field corner there
+	cells := [][]int{{1, 1}, {2, 1}, {1, 2}, {2, 2}, {7, 5}, {8, 6}, {6, 7}, {7, 7}, {8, 7}}
+	const steps = 40
+	for _, e := range engineNames() {
+		t.Run(e, func(t *testing.T) {
+			o := testOptions(ConwayRule, TopologyBounded)
+			o.Width, o.Height = 12, 12
+			o.History = steps
+			//the small hashlife cache is collected during the run, the restored nodes must be canonical again
+			o.Advanced = map[string]interface{}{AdvNodeCacheSize: 64}
+			stateCh := newStateCh()
+			u := engines[e](o, stateCh)
+			defer u.Close()
+			if u.Options().Advanced["Field"] != "unbounded" {
+				return
+			}
+			u.Settle(cells)
+			type state struct {
+				cells      [][]int
+				liveCells  int
+				origin     interface{}
+				generation interface{}
+			}
+			current := func() state {
+				st := u.Status()
+				return state{areaCells(u.Area()), st.LiveCells, st.Details["Window origin"], st.Details["Generation"]}
+			}
+			states := []state{current()}
+			states[0].liveCells = len(cells)
+			for i := 1; i <= steps; i++ {
+				stepAndWait(u, stateCh)
+				states = append(states, current())
+			}
+			checkState := func(action string, iteration int) {
+				t.Helper()
+				if n := u.Status().IterationNum; n != iteration {
+					t.Fatalf("%s: step %d, expected %d", action, n, iteration)
+				}
+				if s := current(); !reflect.DeepEqual(s, states[iteration]) {
+					t.Fatalf("%s: the state %+v differs from the step %d %+v", action, s, iteration, states[iteration])
+				}
+			}
+
+			u.Rewind(10)
+			waitManual(stateCh)
+			checkState("rewind", steps-10)
+			u.Rewind(steps)
+			waitManual(stateCh)
+			checkState("rewind to start", 0)
+			u.Redo()
+			waitManual(stateCh)
+			checkState("redo", 1)
+			//the simulation repeats from the restored state
+			for i := 2; i <= steps; i++ {
+				stepAndWait(u, stateCh)
+				checkState("step after undo", i)
+			}
+		})
+	}
+}
+
+func Test_Snapshot(t *testing.T) {
+	//the small field is saved in JSON, the large one in the binary format
+	for _, size := range []int{50, testFieldSize} {
+		o := testOptions(MustParseRule("B36/S23"), TopologyTorus)
+		o.Width, o.Height = size, size*3/4
+		cells := randomCells(1, 0, 0, o.Width, o.Height)
+		for _, e := range engineNames() {
+			t.Run(fmt.Sprintf("%s/%dx%d", e, o.Width, o.Height), func(t *testing.T) {
+				if unboundedEngines[e] {
+					return
+				}
+				stateCh := newStateCh()
+				u := engines[e](o, stateCh)
+				defer u.Close()
+				u.Settle(cells)
+				for i := 0; i < 5; i++ {
+					stepAndWait(u, stateCh)
+				}
+				var b bytes.Buffer
+				if err := u.SaveSnapshot(&b); err != nil {
+					t.Fatal(err)
+				}
+				if isJSON := b.Bytes()[0] == '{'; isJSON != (o.Width*o.Height <= SnapshotJSONMaxCells) {
+					t.Fatalf("JSON format is %v for the field %dx%d", isJSON, o.Width, o.Height)
+				}
+				saved := areaCells(u.Area())
+				stepAndWait(u, stateCh)
+				expected := areaCells(u.Area())
+
+				rStateCh := newStateCh()
+				r := engines[e](o, rStateCh)
+				defer r.Close()
+				if err := r.LoadSnapshot(bytes.NewReader(b.Bytes())); err != nil {
+					t.Fatal(err)
+				}
+				waitManual(rStateCh)
+				if st := r.Status(); st.IterationNum != 5 || st.LiveCells != len(saved) || !reflect.DeepEqual(areaCells(r.Area()), saved) {
+					t.Fatalf("the restored step %d, %d live cells, expected step 5, %d live cells", st.IterationNum, st.LiveCells, len(saved))
+				}
+				stepAndWait(r, rStateCh)
+				if !reflect.DeepEqual(areaCells(r.Area()), expected) {
+					t.Fatal("the simulation continued from the restored state differs")
+				}
+			})
+		}
+	}
+
+	//the snapshot of the other field can't be loaded
+	o := testOptions(ConwayRule, TopologyBounded)
+	s := Snapshot{Options: *o, Area: createArea(o.Width+1, o.Height)}
+	var b bytes.Buffer
+	if err := WriteSnapshot(&b, s); err != nil {
+		t.Fatal(err)
+	}
+	u := NewBaseUniverse(o, nil)
+	defer u.Close()
+	if err := u.LoadSnapshot(&b); err == nil {
+		t.Fatal("the snapshot with the other field size is loaded")
+	}
+	if _, err := ReadSnapshot(bytes.NewReader([]byte(`{"format": "simlife snapshot", "version": 99}`))); err == nil {
+		t.Fatal("the snapshot with unsupported version is loaded")
+	}
+}
+
+func Test_SnapshotUnbounded(t *testing.T) {
+	//the glider flies away from the block, the unbounded engines keep both of them outside the window
+	cells := [][]int{{1, 1}, {2, 1}, {1, 2}, {2, 2}, {7, 5}, {8, 6}, {6, 7}, {7, 7}, {8, 7}}
+	for e := range unboundedEngines {
+		o := testOptions(ConwayRule, TopologyBounded)
+		o.Width, o.Height = 12, 12
+		stateCh := newStateCh()
+		u := engines[e](o, stateCh)
+		u.Settle(cells)
+		for i := 0; i < 30; i++ {
+			stepAndWait(u, stateCh)
+		}
+		if st := u.Status(); st.LiveCells != len(cells) || len(areaCells(u.Area())) == len(cells) {
+			t.Fatalf("%s: %d live cells, %d inside the window, expected %d live cells partly outside",
+				e, st.LiveCells, len(areaCells(u.Area())), len(cells))
+		}
+		var b bytes.Buffer
+		if err := u.SaveSnapshot(&b); err != nil {
+			t.Fatal(err)
+		}
+		saved := areaCells(u.Area())
+		for i := 0; i < 10; i++ {
+			stepAndWait(u, stateCh)
+		}
+		expected, expectedStatus := areaCells(u.Area()), u.Status()
+		u.Close()
+
+		rStateCh := newStateCh()
+		r := engines[e](o, rStateCh)
+		if err := r.LoadSnapshot(bytes.NewReader(b.Bytes())); err != nil {
+			t.Fatal(err)
+		}
+		waitManual(rStateCh)
+		if st := r.Status(); st.LiveCells != len(cells) || !reflect.DeepEqual(areaCells(r.Area()), saved) {
+			t.Fatalf("%s: the restored %d live cells, expected %d", e, st.LiveCells, len(cells))
+		}
+		for i := 0; i < 10; i++ {
+			stepAndWait(r, rStateCh)
+		}
+		st := r.Status()
+		if st.LiveCells != expectedStatus.LiveCells || !reflect.DeepEqual(areaCells(r.Area()), expected) ||
+			st.Details["Window origin"] != expectedStatus.Details["Window origin"] {
+			t.Fatalf("%s: the simulation continued from the restored state differs: %d live cells, origin %v, expected %d, %v",
+				e, st.LiveCells, st.Details["Window origin"], expectedStatus.LiveCells, expectedStatus.Details["Window origin"])
+		}
+		r.Close()
+
+		//the bounded engine can't keep the cells outside the field
+		bu := NewBaseUniverse(o, nil)
+		if err := bu.LoadSnapshot(bytes.NewReader(b.Bytes())); err == nil {
+			t.Fatalf("%s: the snapshot with the cells outside the field is loaded by the bounded engine", e)
+		}
+		bu.Close()
+	}
+
+	//the large field is saved in the binary format with the cells outside after the bitmap
+	o := testOptions(ConwayRule, TopologyBounded)
+	s := Snapshot{Options: *o, IterationNum: 3, Area: createArea(o.Width, o.Height), OriginX: -7, OriginY: 5, Outside: [][]int{{-1000, 5}, {300, -3}}}
+	s.Area.Entities[1][2] = true
+	var b bytes.Buffer
+	if err := WriteSnapshot(&b, s); err != nil {
+		t.Fatal(err)
+	}
+	r, err := ReadSnapshot(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.OriginX != s.OriginX || r.OriginY != s.OriginY || !reflect.DeepEqual(r.Outside, s.Outside) || !reflect.DeepEqual(areaCells(r.Area), [][]int{{2, 1}}) {
+		t.Fatalf("read %+v, expected %+v", r, s)
+	}
+}
+
+func Test_Soup(t *testing.T) {
+	for _, sym := range []Symmetry{SymmetryC1, SymmetryC2, SymmetryC4, SymmetryD8} {
+		s := Soup{Seed: 7, Density: 0.3, X: 5, Y: 3, Width: 30, Height: 20, Symmetry: sym}
+		cells := sortCells(s.Cells(100, 100))
+		if !reflect.DeepEqual(sortCells(s.Cells(100, 100)), cells) {
+			t.Fatalf("%v: the soup with the same seed differs", sym)
+		}
+		x, y, w, h := s.Region(100, 100)
+		if n := len(cells); n < w*h/5 || n > w*h*2/5 {
+			t.Fatalf("%v: %d live cells in the region %dx%d with the density %v", sym, n, w, h, s.Density)
+		}
+		live := map[point]bool{}
+		for _, c := range cells {
+			if c[0] < x || c[1] < y || c[0] >= x+w || c[1] >= y+h {
+				t.Fatalf("%v: the cell %v is outside the region", sym, c)
+			}
+			live[point{c[0] - x, c[1] - y}] = true
+		}
+		for p := range live {
+			for _, i := range sym.orbit(p.x, p.y, w, h) {
+				if !live[i] {
+					t.Fatalf("%v: the cell %v is live, the symmetric cell %v is dead", sym, p, i)
+				}
+			}
+		}
+	}
+}
+
+func Test_SoupDensity(t *testing.T) {
+	for _, d := range []float64{0, -0.5, 1.5, math.NaN()} {
+		if err := (Soup{Density: d}).Validate(); err == nil {
+			t.Fatalf("the density %v is accepted", d)
+		}
+	}
+	if err := (Soup{Density: 1}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+	o := testOptions(ConwayRule, TopologyBounded)
+	o.Soup.Density = 0
+	u := NewBaseUniverse(o, newStateCh())
+	defer u.Close()
+	if err := u.SettleWithRandomData(); err == nil {
+		t.Fatal("the soup with the zero density is settled")
+	}
+}
+
+func Test_MultithreadedClose(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+	o := testOptions(ConwayRule, TopologyBounded)
+	o.Advanced = map[string]interface{}{AdvWorkers: 4}
+	stateCh := newStateCh()
+	u := NewMultithreadedUniverse(o, stateCh)
+	stepAndWait(u, stateCh)
+	u.Close()
+	u.Close()
+	//the workers and the main loop exit asynchronously
+	for i := 0; runtime.NumGoroutine() > goroutines; i++ {
+		if i == 100 {
+			t.Fatalf("%d goroutines are running, expected %d", runtime.NumGoroutine(), goroutines)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_SetAdvanced(t *testing.T) {
+	shared := map[string]interface{}{AdvWorkers: 4, AdvTileSize: 16}
+	o := Options{Advanced: shared}
+	o.SetAdvanced(map[string]interface{}{AdvWorkers: 2})
+	if o.Advanced[AdvWorkers] != 2 || o.Advanced[AdvTileSize] != 16 {
+		t.Fatalf("the merged options %v", o.Advanced)
+	}
+	if shared[AdvWorkers] != 4 {
+		t.Fatalf("the shared map is modified: %v", shared)
+	}
+	//the engine options don't leak to the caller's map
+	u := NewBaseUniverse(&o, nil)
+	defer u.Close()
+	if u.Options().Advanced["engine"] != "base" || o.Advanced["engine"] != nil {
+		t.Fatalf("the engine option: %v, the caller's options %v", u.Options().Advanced, o.Advanced)
+	}
+}
+
+func Test_UnboundedOptions(t *testing.T) {
+	for _, o := range []*Options{testOptions(MustParseRule("B03/S23"), TopologyBounded), testOptions(ConwayRule, TopologyTorus)} {
+		for name, newUniverse := range map[string]func(o *Options, stateCh chan Status) (Universe, error){
+			"hashlife": NewHashLifeUniverse,
+			"sparse":   NewSparseUniverse,
+		} {
+			if u, err := newUniverse(o, nil); err == nil {
+				u.Close()
+				t.Fatalf("%s: the rule %v and the topology %v are accepted", name, o.Rule, o.Topology)
+			}
+		}
+	}
+}
+
+func Test_HashLifeOptions(t *testing.T) {
+	o := testOptions(ConwayRule, TopologyBounded)
+	o.Advanced = map[string]interface{}{AdvStepExponent: 62}
+	u, err := NewHashLifeUniverse(o, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+	if e := u.Options().Advanced[AdvStepExponent]; e != MaxHashLifeStepExponent {
+		t.Fatalf("the step exponent %v, expected %d", e, MaxHashLifeStepExponent)
+	}
+}
+
+func Test_HashLifeCycles(t *testing.T) {
+	var pulsar [][]int
+	for _, a := range []int{0, 5, 7, 12} {
+		for _, b := range []int{2, 3, 4, 8, 9, 10} {
+			pulsar = append(pulsar, []int{10 + b, 10 + a}, []int{10 + a, 10 + b})
+		}
+	}
+	tests := []struct {
+		name    string
+		cells   [][]int
+		stepExp int
+		reason  FinishReason
+		period  int
+	}{
+		{"blinker", blinker, 0, FinishReasonOscillator, 2},
+		{"blinker step 4", blinker, 2, FinishReasonOscillator, 2},
+		{"block step 4", [][]int{{10, 10}, {11, 10}, {10, 11}, {11, 11}}, 2, FinishReasonStillLife, 1},
+		{"pulsar", pulsar, 0, FinishReasonOscillator, 3},
+		{"pulsar step 2", pulsar, 1, FinishReasonOscillator, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := testOptions(ConwayRule, TopologyBounded)
+			o.CycleHistory = 10
+			//the tiny cache is collected every step, the dropped nodes are joined again with the new ids
+			o.Advanced = map[string]interface{}{AdvStepExponent: tt.stepExp, AdvNodeCacheSize: 1}
+			stateCh := newStateCh()
+			u, err := NewHashLifeUniverse(o, stateCh)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer u.Close()
+			u.Settle(tt.cells)
+			for i := 0; i < 20 && u.Status().RunningMode != RunningStateFinished; i++ {
+				stepAndWait(u, stateCh)
+			}
+			if st := u.Status(); st.FinishReason != tt.reason || st.Period != tt.period {
+				t.Fatalf("finished with %v (period %d), expected %v (period %d)", st.FinishReason, st.Period, tt.reason, tt.period)
+			}
+		})
+	}
+}
+
+func Test_Placement(t *testing.T) {
+	glider := Template{Name: "glider", Coordinates: [][]int{{11, 10}, {12, 11}, {10, 12}, {11, 12}, {12, 12}}}
+	tests := []struct {
+		p     Placement
+		cells [][]int
+	}{
+		{Placement{X: 5, Y: 7}, [][]int{{6, 7}, {7, 8}, {5, 9}, {6, 9}, {7, 9}}},
+		{Placement{X: 5, Y: 7, Rotation: 90}, [][]int{{5, 7}, {5, 8}, {7, 8}, {5, 9}, {6, 9}}},
+		{Placement{X: 5, Y: 7, Rotation: 180}, [][]int{{5, 7}, {6, 7}, {7, 7}, {5, 8}, {6, 9}}},
+		{Placement{X: 5, Y: 7, Rotation: -90}, [][]int{{6, 7}, {7, 7}, {5, 8}, {7, 8}, {7, 9}}},
+		{Placement{X: 5, Y: 7, FlipH: true}, [][]int{{6, 7}, {5, 8}, {5, 9}, {6, 9}, {7, 9}}},
+		{Placement{X: 5, Y: 7, FlipV: true}, [][]int{{5, 7}, {6, 7}, {7, 7}, {7, 8}, {6, 9}}},
+		{Placement{X: 6, Y: 8, Center: true}, [][]int{{6, 7}, {7, 8}, {5, 9}, {6, 9}, {7, 9}}},
+	}
+	for _, tt := range tests {
+		tmpl, err := glider.Transform(tt.p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(sortCells(tmpl.Coordinates), sortCells(tt.cells)) {
+			t.Fatalf("%+v: %v, expected %v", tt.p, sortCells(tmpl.Coordinates), sortCells(tt.cells))
+		}
+	}
+	if _, err := glider.Transform(Placement{Rotation: 45}); err == nil {
+		t.Fatal("the rotation by 45 degrees is accepted")
+	}
+
+	//two gliders moving towards each other collide and leave the debris
+	stateCh := make(chan Status, 10)
+	u := NewBaseUniverse(testOptions(ConwayRule, TopologyBounded), stateCh)
+	defer u.Close()
+	u.AddTemplate(glider)
+	if err := u.SettleTemplateAt("glider", Placement{X: 10, Y: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.SettleTemplateAt("glider", Placement{X: 30, Y: 30, Rotation: 180}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		stepAndWait(u, stateCh)
+	}
+	if st := u.Status(); st.LiveCells == 10 {
+		t.Fatalf("the gliders haven't collided: %+v", st)
+	}
+
+	if err := u.SettleTemplateAt("glider", Placement{X: 199, Y: 0}); err == nil {
+		t.Fatal("the cells outside the field are dropped silently")
+	}
+	if err := u.SettleTemplateAt("unknown", Placement{}); err == nil {
+		t.Fatal("the unknown template is settled")
+	}
+}
+
+func Test_Activity(t *testing.T) {
+	block := [][]int{{10, 10}, {11, 10}, {10, 11}, {11, 11}}
+	for _, e := range engineNames() {
+		o := testOptions(ConwayRule, TopologyBounded)
+		o.TrackActivity = true
+		stateCh := newStateCh()
+		u := engines[e](o, stateCh)
+		u.Settle(append(append([][]int{}, blinker...), block...))
+		var acts []*Activity
+		for i := 1; i <= 5; i++ {
+			stepAndWait(u, stateCh)
+			//the blinker ends die and the new ones are born on every step
+			if st := u.Status(); st.Births != 2 || st.Deaths != 2 {
+				t.Fatalf("%s: step %d: %d births, %d deaths, expected 2 and 2", e, i, st.Births, st.Deaths)
+			}
+			act := u.Activity()
+			if age := act.Cells[10][10].Age; age != int32(i+1) {
+				t.Fatalf("%s: step %d: the block age is %d", e, i, age)
+			}
+			if age := act.Cells[100][101].Age; age != int32(i+1) {
+				t.Fatalf("%s: step %d: the blinker centre age is %d", e, i, age)
+			}
+			if c := act.Cells[100][100]; c.Died != (i%2 == 1) || c.Heat != int32(i) || act.MaxHeat != int32(i) {
+				t.Fatalf("%s: step %d: unexpected blinker end activity %+v, max heat %d", e, i, c, act.MaxHeat)
+			}
+			acts = append(acts, act)
+		}
+		//the activity kept by the caller isn't modified by the next steps
+		for i, act := range acts {
+			if act.MaxHeat != int32(i+1) || act.Cells[10][10].Age != int32(i+2) {
+				t.Fatalf("%s: the activity of the step %d is modified, max heat %d", e, i+1, act.MaxHeat)
+			}
+		}
+		//the edit doesn't count as the birth, the clear resets the heat
+		u.InverseCell(50, 50)
+		if c := u.Activity().Cells[50][50]; c.Age != 1 || c.Heat != 0 {
+			t.Fatalf("%s: unexpected settled cell activity %+v", e, c)
+		}
+		u.Clear()
+		waitManual(stateCh)
+		if act := u.Activity(); act.MaxHeat != 0 || act.Cells[10][10].Age != 0 {
+			t.Fatalf("%s: the activity isn't reset by the clear", e)
+		}
+		u.Close()
+	}
+}
+
+func Test_ActivityWindowMove(t *testing.T) {
+	//the glider flies away from the block, the unbounded engines moving the window keep the block activity
+	block := [][]int{{1, 1}, {2, 1}, {1, 2}, {2, 2}}
+	cells := append([][]int{{7, 5}, {8, 6}, {6, 7}, {7, 7}, {8, 7}}, block...)
+	for _, e := range engineNames() {
+		o := testOptions(ConwayRule, TopologyBounded)
+		o.Width, o.Height = 12, 12
+		o.TrackActivity = true
+		stateCh := newStateCh()
+		u := engines[e](o, stateCh)
+		u.Settle(cells)
+		moved := false
+		for i := 1; i <= 40; i++ {
+			stepAndWait(u, stateCh)
+			originX, originY := 0, 0
+			if origin, ok := u.Status().Details["Window origin"]; ok {
+				_, _ = fmt.Sscanf(fmt.Sprint(origin), "%d,%d", &originX, &originY)
+			}
+			moved = moved || originX != 0 || originY != 0
+			act := u.Activity()
+			for _, c := range block {
+				x, y := c[0]-originX, c[1]-originY
+				if x < 0 || y < 0 || x >= o.Width || y >= o.Height {
+					continue
+				}
+				if a := act.Cells[y][x]; a.Age != int32(i+1) || a.Heat != 0 {
+					t.Fatalf("%s: step %d: the block cell %v activity %+v, origin %d,%d", e, i, c, a, originX, originY)
+				}
+			}
+			if act.Births > 8 || act.Deaths > 8 {
+				t.Fatalf("%s: step %d: %d births, %d deaths of the glider", e, i, act.Births, act.Deaths)
+			}
+		}
+		if e == "sparse" && !moved {
+			t.Fatalf("%s: the window doesn't move", e)
+		}
+		u.Close()
+	}
+}
+
+func Test_Edit(t *testing.T) {
+	for _, e := range engineNames() {
+		t.Run(e, func(t *testing.T) {
+			o := testOptions(ConwayRule, TopologyBounded)
+			o.History = 5
+			stateCh := newStateCh()
+			u := engines[e](o, stateCh)
+			defer u.Close()
+			u.Settle(shiftCells(glider, -90, -90))
+			editAndWait(u, Edit{
+				Clear:  Region{X: 12, Y: 10, Width: 5, Height: 5},
+				Dead:   [][]int{{11, 10}, {50, 50}},
+				Live:   [][]int{{50, 50}, {51, 50}, {-1, 0}},
+				Invert: [][]int{{51, 50}, {52, 50}},
+			})
+			expected := [][]int{{10, 12}, {11, 12}, {50, 50}, {52, 50}}
+			if cells := areaCells(u.Area()); !reflect.DeepEqual(cells, expected) || u.Status().LiveCells != len(expected) {
+				t.Fatalf("the area %v, %d live cells, expected %v", cells, u.Status().LiveCells, expected)
+			}
+
+			//the random soup fills the region only
+			editAndWait(u, Edit{Clear: Region{X: 190, Y: 190, Width: 20, Height: 20}, Random: true})
+			cells := areaCells(u.Area())
+			for _, c := range cells[len(expected):] {
+				if c[0] < 190 || c[1] < 190 {
+					t.Fatalf("the random cell %v outside the region", c)
+				}
+			}
+			if len(cells) < len(expected)+10 {
+				t.Fatalf("%d cells in the random region", len(cells)-len(expected))
+			}
+			//every edit is the undo step
+			u.Undo()
+			waitManual(stateCh)
+			if cells := areaCells(u.Area()); !reflect.DeepEqual(cells, expected) {
+				t.Fatalf("undo: the area %v, expected %v", cells, expected)
+			}
+
+			//the edits are done between the steps of the running simulation, the blinker keeps it running
+			u.Settle(blinker)
+			started, done := make(chan bool), make(chan bool)
+			go func() {
+				for st := range stateCh {
+					if st.RunningMode == RunningStateRun {
+						break
+					}
+				}
+				close(started)
+				waitManual(stateCh)
+				close(done)
+			}()
+			u.Run()
+			<-started
+			for i := 0; i < 20; i++ {
+				u.Edit(Edit{Live: [][]int{{150, 150 + i}}})
+			}
+			u.Stop()
+			<-done
+		})
+	}
+}
+
+//snapshot is the universe state after the step
+type snapshot struct {
+	cells     [][]int
+	liveCells int
+}
+
+//compareEngines runs the cells through all engines and compares the snapshots with the reference engine
+func compareEngines(t *testing.T, o *Options, cells [][]int, steps int, boundedOnly bool) {
+	reference := runSnapshots(t, referenceEngine, o, cells, steps)
+	for _, e := range engineNames() {
+		if e == referenceEngine {
+			continue
+		}
+		if boundedOnly && unboundedEngines[e] {
+			continue
+		}
+		for step, s := range runSnapshots(t, e, o, cells, steps) {
+			compareSnapshots(t, e, step, s, reference[step])
+		}
+	}
+}
+
+//compareSnapshots compares the snapshot with the reference one
+func compareSnapshots(t *testing.T, engine string, step int, s snapshot, reference snapshot) {
+	t.Helper()
+	if s.liveCells != reference.liveCells {
+		t.Fatalf("%s: step %d: %d live cells, %s has %d", engine, step, s.liveCells, referenceEngine, reference.liveCells)
+	}
+	if !reflect.DeepEqual(s.cells, reference.cells) {
+		t.Fatalf("%s: step %d: the area differs from %s", engine, step, referenceEngine)
+	}
+}
+
+//runSnapshots runs the engine with the cells and returns the snapshots of the initial state and the each step
+func runSnapshots(t *testing.T, engine string, o *Options, cells [][]int, steps int) []snapshot {
+	t.Helper()
+	stateCh := newStateCh()
+	u := engines[engine](o, stateCh)
+	u.Settle(cells)
+	initial := areaCells(u.Area())
+	snapshots := []snapshot{{initial, len(initial)}}
+	for i := 0; i < steps; i++ {
+		stepAndWait(u, stateCh)
+		snapshots = append(snapshots, snapshot{areaCells(u.Area()), u.Status().LiveCells})
+	}
+	u.Close()
+	return snapshots
+}
+
+//stepAndWait does the step and waits for the step finishing
+func stepAndWait(u Universe, stateCh chan Status) {
+	u.Step()
+	for {
+		st := <-stateCh
+		if st.RunningMode != RunningStateStep {
+			break
+		}
+	}
+}
+
+//editAndWait does the edit and waits for the edit finishing, the control channel commands are executed in order
+func editAndWait(u Universe, e Edit) {
+	u.Edit(e)
+	_ = u.SaveSnapshot(&bytes.Buffer{})
+}
+
+//waitManual waits for the switching to the manual mode
+func waitManual(stateCh chan Status) {
+	for {
+		if st := <-stateCh; st.RunningMode == RunningStateManual {
+			break
+		}
+	}
+}
+
+//testOptions returns the options to run the tests
+func testOptions(rule Rule, topology Topology) *Options {
+	o := DefaultUniverseOptions
+	o.Interval = 0
+	o.Width = testFieldSize
+	o.Height = testFieldSize
+	o.MaxSteps = 0
+	o.CycleHistory = 0
+	o.Rule = rule
+	o.Topology = topology
+	return &o
+}
+
+//areaCells returns the live cells of the area in the scan order
+func areaCells(a Area) [][]int {
+	cells := [][]int{}
+	for y := range a.Entities {
+		for x, e := range a.Entities[y] {
+			if e {
+				cells = append(cells, []int{x, y})
+			}
+		}
+	}
+	return cells
+}
+
+//sortCells returns the cells sorted in the scan order
+func sortCells(cells [][]int) [][]int {
+	maxX, maxY := 0, 0
+	for _, c := range cells {
+		if c[0] > maxX {
+			maxX = c[0]
+		}
+		if c[1] > maxY {
+			maxY = c[1]
+		}
+	}
+	a := createArea(maxX+1, maxY+1)
+	for _, c := range cells {
+		a.Entities[c[1]][c[0]] = true
+	}
+	return areaCells(a)
+}
+
+//shiftCells returns the cells moved by dx, dy
+func shiftCells(cells [][]int, dx int, dy int) [][]int {
+	shifted := make([][]int, 0, len(cells))
+	for _, c := range cells {
+		shifted = append(shifted, []int{c[0] + dx, c[1] + dy})
+	}
+	return shifted
+}
+
+//randomCells returns the random cells with the density 1/3 inside the rectangle
+func randomCells(seed int64, x int, y int, width int, height int) [][]int {
+	rnd := rand.New(rand.NewSource(seed))
+	cells := [][]int{}
+	for i := 0; i < width*height/3; i++ {
+		cells = append(cells, []int{x + rnd.Intn(width), y + rnd.Intn(height)})
+	}
+	return cells
+}
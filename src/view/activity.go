@@ -0,0 +1,92 @@
+package view
+
+import (
+	"github.com/jroimartin/gocui"
+	"github.com/logrusorgru/aurora"
+	"simlife/src/universe"
+)
+
+/*
+	The activity colouring of the battle field
+	the live cells are coloured by the age: the newborn cells are white, the young ones yellow, the older ones green,
+	the long living cells (the still lifes, the slow oscillators) cyan and blue, the cells died in the last step are red
+	the heatmap overlay shades the background by the cumulative number of the births and deaths,
+	so the regions which are still churning are visible
+	the UI runs in the 8 colours terminal mode, so the gradients are short
+	the aggregated glyph is coloured by the mean age of its live cells and by the maximum heat
+*/
+
+//ageColours are the colours of the live cells by the minimum age, the oldest first
+var ageColours = []struct {
+	age    int32
+	colour aurora.Color
+}{
+	{100, aurora.BlueFg},
+	{16, aurora.CyanFg},
+	{4, aurora.GreenFg},
+	{2, aurora.YellowFg},
+	{1, aurora.WhiteFg | aurora.BoldFm},
+}
+
+//heatColours are the background colours by the heat relative to the maximum heat of the field, the coldest first
+var heatColours = []aurora.Color{aurora.BlueBg, aurora.MagentaBg, aurora.RedBg, aurora.YellowBg}
+
+//activityColour returns the colour of the glyph with the top-left cell x,y, 0 if the glyph isn't coloured by the activity
+func (t *ConsoleUI) activityColour(act *universe.Activity, x int, y int) aurora.Color {
+	if act == nil || (!t.ageColours && !t.heatmap) {
+		return 0
+	}
+	gw, gh := t.view.glyphSize()
+	live, died := 0, 0
+	age, heat := int64(0), int32(0)
+	for cy := y; cy < y+gh && cy < act.Height; cy++ {
+		row := act.Cells[cy]
+		for cx := x; cx < x+gw && cx < act.Width; cx++ {
+			c := row[cx]
+			if c.Age > 0 {
+				live++
+				age += int64(c.Age)
+			} else if c.Died {
+				died++
+			}
+			if c.Heat > heat {
+				heat = c.Heat
+			}
+		}
+	}
+
+	var colour aurora.Color
+	if t.ageColours {
+		switch {
+		case live > 0:
+			mean := int32(age / int64(live))
+			for _, ac := range ageColours {
+				if mean >= ac.age {
+					colour = ac.colour
+					break
+				}
+			}
+		case died > 0:
+			colour = aurora.RedFg
+		}
+	}
+	if t.heatmap && heat > 0 && act.MaxHeat > 0 {
+		n := int32(len(heatColours))
+		colour |= heatColours[(heat*n-1)/act.MaxHeat]
+	}
+	return colour
+}
+
+//cmdAgeColours calls by gocui key handler and switches the colouring of the cells by the age
+func (t *ConsoleUI) cmdAgeColours(_ *gocui.View) error {
+	t.ageColours = !t.ageColours
+	t.renderField(t.u.Area())
+	return nil
+}
+
+//cmdHeatmap calls by gocui key handler and switches the heatmap overlay
+func (t *ConsoleUI) cmdHeatmap(_ *gocui.View) error {
+	t.heatmap = !t.heatmap
+	t.renderField(t.u.Area())
+	return nil
+}
@@ -1,33 +1,64 @@
 package view
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"simlife/src/analysis"
 	"simlife/src/universe"
 	"sort"
+	"strings"
+	"text/tabwriter"
 	"time"
 )
 
 type ConsoleOut struct {
-	u         universe.Universe
-	startTime time.Time
+	u          universe.Universe
+	startTime  time.Time
+	censusFile string    //the file to write the census of the final state in JSON, "-" for the standard output
+	finished   chan bool //closed when the final output is printed
+	escapes    bool      //report the escaping spaceships
+	detector   *analysis.EscapeDetector
 }
 
 func NewConsoleOut() *ConsoleOut {
-	return &ConsoleOut{}
+	return &ConsoleOut{finished: make(chan bool)}
 }
 
 func (c *ConsoleOut) Refresh() {
 	st := c.u.Status()
+	if c.detector != nil {
+		for _, e := range c.detector.Add(c.u.Area(), st.IterationNum) {
+			fmt.Printf("  Escaped: %v\n", e)
+		}
+	}
 	if st.RunningMode == universe.RunningStateFinished {
 		totalTime := time.Since(c.startTime).Round(time.Millisecond)
 		resultData := map[string]interface{}{
 			"Last iteration": st.IterationNum,
 			"Total time":     totalTime,
 			"Live cells":     st.LiveCells,
+			"Finish reason":  st.FinishDescr(),
+		}
+		for k, v := range st.Details {
+			resultData[k] = v
+		}
+		if c.detector != nil {
+			resultData["Escaped"] = escapeSummary(c.detector.Escapes())
 		}
 		fmt.Println("\nFinished:")
 		c.printHashData(resultData)
+		if st.FinishReason.Stabilized() {
+			//the census of the chaotic state isn't meaningful and takes long
+			c.printCensus(analysis.TakeCensus(c.u.Area(), c.u.Options().Rule))
+		}
 		fmt.Println("")
+		select {
+		case <-c.finished:
+		default:
+			close(c.finished)
+		}
 	} else if st.RunningMode == universe.RunningStateRun {
 		if st.IterationNum%10 == 0 {
 			fmt.Printf("  Iterations done: %v\n", st.IterationNum)
@@ -35,13 +66,33 @@ func (c *ConsoleOut) Refresh() {
 	}
 }
 
+//SetCensusFile sets the file to write the census of the final state in JSON, "-" for the standard output
+func (c *ConsoleOut) SetCensusFile(name string) {
+	c.censusFile = name
+}
+
+//SetEscapes enables the report of the spaceships escaping the reaction region
+func (c *ConsoleOut) SetEscapes(detect bool) {
+	c.escapes = detect
+}
+
+//Wait waits until the final output is printed
+func (c *ConsoleOut) Wait() {
+	<-c.finished
+}
+
 func (c *ConsoleOut) Register(u *universe.BaseUniverse) {
 	c.u = u
 	o := c.u.Options()
+	if c.escapes {
+		c.detector = analysis.NewEscapeDetector(o.Rule)
+	}
 	fmt.Println("Running configuration:")
 	fmt.Printf("  Dimension: %v x %v\n", o.Width, o.Height)
 	fmt.Printf("  Interval: %v\n", o.Interval)
 	fmt.Printf("  Max iterations: %v steps\n", o.MaxSteps)
+	fmt.Printf("  Rule: %v\n", o.Rule)
+	fmt.Printf("  Topology: %v\n", o.Topology)
 	c.printHashData(o.Advanced)
 }
 
@@ -60,3 +111,62 @@ func (c *ConsoleOut) printHashData(d map[string]interface{}) {
 		fmt.Printf("  %s: %v\n", propName, d[propName])
 	}
 }
+
+//printCensus prints the objects of the final state, the census is written to the census file if it's set
+func (c *ConsoleOut) printCensus(census analysis.Census) {
+	if len(census.Entries) > 0 {
+		fmt.Println("  Census:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "    COUNT\tOBJECT\tKIND\tPERIOD\tCELLS\tCODE")
+		for _, e := range census.Entries {
+			name, period := e.Name, "-"
+			if name == "" {
+				name = "unnamed"
+			}
+			if e.Period > 0 {
+				period = fmt.Sprint(e.Period)
+			}
+			_, _ = fmt.Fprintf(w, "    %d\t%s\t%s\t%s\t%d\t%s\n", e.Count, name, e.Kind, period, e.Cells, e.Code)
+		}
+		_ = w.Flush()
+	}
+	if c.censusFile == "" {
+		return
+	}
+	data, err := json.MarshalIndent(census, "", "  ")
+	if err == nil {
+		if c.censusFile == "-" {
+			fmt.Println(string(data))
+			return
+		}
+		err = ioutil.WriteFile(c.censusFile, append(data, '\n'), 0644)
+	}
+	if err != nil {
+		fmt.Printf("  Census error: %v\n", err)
+	}
+}
+
+//escapeSummary returns the number of the escaped spaceships by their names
+func escapeSummary(escapes []analysis.Escape) string {
+	if len(escapes) == 0 {
+		return "0"
+	}
+	counts := map[string]int{}
+	names := []string{}
+	for _, e := range escapes {
+		name := e.Name
+		if name == "" {
+			name = e.Code
+		}
+		if counts[name] == 0 {
+			names = append(names, name)
+		}
+		counts[name]++
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%d %s", counts[name], name)
+	}
+	return fmt.Sprintf("%d (%s)", len(escapes), strings.Join(parts, ", "))
+}
@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"github.com/jroimartin/gocui"
 	"github.com/logrusorgru/aurora"
+	"github.com/nsf/termbox-go"
 	"log"
+	"os"
+	"simlife/src/analysis"
 	"simlife/src/universe"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,13 +25,35 @@ type keyBindings struct {
 }
 
 type ConsoleUI struct {
-	u          universe.Universe
-	g          *gocui.Gui
-	k          []keyBindings
-	liveFiller string
-	deadFiller string
+	u            universe.Universe
+	g            *gocui.Gui
+	k            []keyBindings
+	liveFiller   string
+	deadFiller   string
+	snapshotFile string //the file to save and load the snapshot
+	message      string //the result of the last command
+	pickerKeys   []keyBindings
+	picker       bool       //the pattern picker panel is shown
+	stamp        bool       //the template selected in the picker is stamped from the clipboard by the next click on the field
+	escapes      bool       //detect the escaping spaceships, the detection segments the whole field every step
+	escapesLock  sync.Mutex //guards the escapes switch and the detector used by the universe goroutine
+	detector     *analysis.EscapeDetector
+	escaped      string //the summary of the escaped spaceships
+	view         viewport
+	ageColours   bool  //colour the cells by the age, the newborn and the dying cells
+	heatmap      bool  //shade the background by the cumulative activity of the cells
+	graph        graph //the history graph panel
+	editor       editor
+	editKeys     []keyBindings
+	fieldW       int //the battle field view size
+	fieldH       int
 }
 
+const (
+	rewindSteps     = 10 //the number of the steps to rewind by the keybinding
+	DefSnapshotFile = "simlife.snapshot"
+)
+
 var (
 	runningStateDescr = map[universe.RunningState]string{
 		universe.RunningStateManual:   aurora.Colorize("waiting", aurora.BlueFg).String(),
@@ -41,8 +67,10 @@ func NewConsoleUI() *ConsoleUI {
 
 	var err error
 	t := ConsoleUI{
-		liveFiller: aurora.Green("█").BgBrightGreen().String(),
-		deadFiller: "░",
+		liveFiller:   aurora.Green("█").BgBrightGreen().String(),
+		deadFiller:   "░",
+		snapshotFile: DefSnapshotFile,
+		ageColours:   true,
 	}
 
 	t.g, err = gocui.NewGui(gocui.OutputNormal)
@@ -82,15 +110,225 @@ func NewConsoleUI() *ConsoleUI {
 			"Settle with random",
 			t.cmdSettleWithRandom,
 			""},
+		{'u',
+			"U",
+			"Undo",
+			t.cmdUndo,
+			""},
+		{'y',
+			"Y",
+			"Redo",
+			t.cmdRedo,
+			""},
+		{'b',
+			"B",
+			fmt.Sprintf("Rewind %v steps", rewindSteps),
+			t.cmdRewind,
+			""},
+		{'v',
+			"V",
+			"Save snapshot",
+			t.cmdSaveSnapshot,
+			""},
+		{'l',
+			"L",
+			"Load snapshot",
+			t.cmdLoadSnapshot,
+			""},
+		{'p',
+			"P",
+			"Patterns",
+			t.cmdPicker,
+			""},
+		{gocui.KeyArrowLeft,
+			"←↑→↓",
+			"Pan",
+			t.cmdMove(-1, 0),
+			"battlefield"},
+		{gocui.KeyArrowUp,
+			"",
+			"",
+			t.cmdMove(0, -1),
+			"battlefield"},
+		{gocui.KeyArrowRight,
+			"",
+			"",
+			t.cmdMove(1, 0),
+			"battlefield"},
+		{gocui.KeyArrowDown,
+			"",
+			"",
+			t.cmdMove(0, 1),
+			"battlefield"},
+		{'-',
+			"-/+",
+			"Zoom out/in",
+			t.cmdZoom(1),
+			"battlefield"},
+		{'+',
+			"",
+			"",
+			t.cmdZoom(-1),
+			"battlefield"},
+		{'=',
+			"",
+			"",
+			t.cmdZoom(-1),
+			"battlefield"},
+		{'f',
+			"F",
+			"Follow the action",
+			t.cmdFollow,
+			""},
+		{'m',
+			"M",
+			"Render mode",
+			t.cmdRenderMode,
+			"battlefield"},
+		{'a',
+			"A",
+			"Age colours",
+			t.cmdAgeColours,
+			""},
+		{'h',
+			"H",
+			"Heatmap",
+			t.cmdHeatmap,
+			""},
+		{'g',
+			"G",
+			"History graph",
+			t.cmdGraph,
+			""},
+		{'d',
+			"D",
+			"Detect escapes",
+			t.cmdEscapes,
+			""},
+		{'e',
+			"E",
+			"Edit mode",
+			t.cmdEdit,
+			""},
 		{gocui.MouseLeft,
 			"MOUSE",
-			"Settle the cell",
+			"Settle the cell or zoom in, drag to pan",
+			t.cmdMouseDown,
+			"battlefield"},
+		{gocui.MouseRight,
+			"",
+			"",
+			t.cmdMouseErase,
+			"battlefield"},
+		{gocui.MouseRelease,
+			"",
+			"",
 			t.cmdMouseClick,
 			"battlefield"},
 	}
+	//the edit mode keys, the handlers do nothing outside the edit mode
+	t.editKeys = []keyBindings{
+		{gocui.KeySpace,
+			"SPACE",
+			"Draw",
+			t.cmdDraw(true),
+			"battlefield"},
+		{'x',
+			"X",
+			"Erase",
+			t.cmdDraw(false),
+			"battlefield"},
+		{'[',
+			"[/]",
+			"Brush size",
+			t.cmdBrush(-1),
+			"battlefield"},
+		{']',
+			"",
+			"",
+			t.cmdBrush(1),
+			"battlefield"},
+		{gocui.KeyEnter,
+			"Enter",
+			"Select",
+			t.cmdSelect,
+			"battlefield"},
+		{gocui.KeyEsc,
+			"Esc",
+			"Deselect",
+			t.cmdDeselect,
+			"battlefield"},
+		{'C',
+			"⇧C",
+			"Copy",
+			t.cmdCopy(false),
+			"battlefield"},
+		{'X',
+			"⇧X",
+			"Cut",
+			t.cmdCopy(true),
+			"battlefield"},
+		{'P',
+			"⇧P",
+			"Paste",
+			t.cmdPaste,
+			"battlefield"},
+		{'R',
+			"⇧R",
+			"Rotate",
+			t.cmdTransform("rotated", (*clipboard).rotate),
+			"battlefield"},
+		{'H',
+			"⇧H",
+			"Flip ↔",
+			t.cmdTransform("flipped", func(c *clipboard) *clipboard { return c.flip(true) }),
+			"battlefield"},
+		{'V',
+			"⇧V",
+			"Flip ↕",
+			t.cmdTransform("flipped", func(c *clipboard) *clipboard { return c.flip(false) }),
+			"battlefield"},
+		{'F',
+			"⇧F",
+			"Fill random",
+			t.cmdFillRandom,
+			"battlefield"},
+	}
+	t.pickerKeys = []keyBindings{
+		{gocui.KeyArrowUp,
+			"↑",
+			"Previous",
+			t.cmdPickerMove(-1),
+			"patterns"},
+		{gocui.KeyArrowDown,
+			"↓",
+			"Next",
+			t.cmdPickerMove(1),
+			"patterns"},
+		{gocui.KeyEnter,
+			"Enter",
+			"Select",
+			t.cmdPickerSelect,
+			"patterns"},
+		{gocui.KeyEsc,
+			"Esc",
+			"Close",
+			t.cmdPicker,
+			"patterns"},
+	}
 	t.g.SetManagerFunc(t.layout)
 
 	t.initKeyBindings(t.k)
+	t.initKeyBindings(t.pickerKeys)
+	t.initKeyBindings(t.editKeys)
+	//the mouse motion with the pressed button is reported with the motion modifier
+	for _, button := range []gocui.Key{gocui.MouseLeft, gocui.MouseRight} {
+		if err = t.g.SetKeybinding("battlefield", button, gocui.Modifier(termbox.ModMotion), func(gui *gocui.Gui, view *gocui.View) error {
+			return t.cmdMouseDrag(view)
+		}); err != nil {
+			log.Panicln(err)
+		}
+	}
 
 	return &t
 }
@@ -109,6 +347,23 @@ func (t *ConsoleUI) Register(u *universe.BaseUniverse) {
 	t.u = u
 }
 
+//SetEscapes enables the detection of the spaceships escaping the reaction region, it can be switched by the keybinding
+func (t *ConsoleUI) SetEscapes(detect bool) {
+	t.escapesLock.Lock()
+	t.escapes = detect
+	t.escapesLock.Unlock()
+}
+
+//SetRenderMode sets the initial render mode of the battle field
+func (t *ConsoleUI) SetRenderMode(mode RenderMode) {
+	t.view.mode = mode
+}
+
+//SetSnapshotFile sets the file to save and load the snapshot
+func (t *ConsoleUI) SetSnapshotFile(name string) {
+	t.snapshotFile = name
+}
+
 //Start starts the main UI loop
 func (t *ConsoleUI) Start() {
 	if err := t.g.MainLoop(); err != nil && err != gocui.ErrQuit {
@@ -119,14 +374,64 @@ func (t *ConsoleUI) Start() {
 
 //Refresh do the display update
 func (t *ConsoleUI) Refresh() {
+	t.detectEscapes()
 	t.renderField(t.u.Area())
 	t.renderConfiguration()
 	t.renderStatus()
+	t.renderGraph()
+}
+
+//detectEscapes finds the spaceships escaped in the current generation, the last one is shown as the message
+//Refresh is called by the universe goroutine after every step and edit, so every generation is analysed,
+//the detection slows down the steps, so it's off by default, the results are passed to the gocui goroutine
+func (t *ConsoleUI) detectEscapes() {
+	t.escapesLock.Lock()
+	if !t.escapes {
+		t.escapesLock.Unlock()
+		return
+	}
+	if t.detector == nil {
+		t.detector = analysis.NewEscapeDetector(t.u.Options().Rule)
+	}
+	escapes := t.detector.Add(t.u.Area(), t.u.Status().IterationNum)
+	summary := escapeSummary(t.detector.Escapes())
+	t.escapesLock.Unlock()
+	t.g.Update(func(g *gocui.Gui) error {
+		t.escapesLock.Lock()
+		on := t.escapes
+		t.escapesLock.Unlock()
+		if !on {
+			//the detection is switched off after the generation is analysed
+			return nil
+		}
+		t.escaped = summary
+		if len(escapes) > 0 {
+			t.message = "escaped " + escapes[len(escapes)-1].String()
+		}
+		return nil
+	})
+}
+
+//cmdEscapes calls by gocui key handler and switches the detection of the escaping spaceships
+//the detection starts from the next step
+func (t *ConsoleUI) cmdEscapes(_ *gocui.View) error {
+	t.escapesLock.Lock()
+	t.escapes = !t.escapes
+	t.detector = nil
+	t.escapesLock.Unlock()
+	t.escaped = ""
+	t.renderStatus()
+	return nil
 }
 
 //renderField renders the main "battle field" panel
+//the visible part of the field is defined by the viewport, the glyphs are drawn by the render mode
 func (t *ConsoleUI) renderField(a universe.Area) {
 
+	act := t.u.Activity()
+	if act != nil && (act.Width != a.Width || act.Height != a.Height) {
+		act = nil
+	}
 	t.g.Update(func(g *gocui.Gui) error {
 		v, e := g.View("battlefield")
 		if e != nil {
@@ -137,36 +442,22 @@ func (t *ConsoleUI) renderField(a universe.Area) {
 		//there is an opportunity to speed up with a selective redraw
 		v.Clear()
 
-		crop := false
 		maxW, maxH := v.Size()
-		if a.Width > maxW || a.Height > maxH {
-			crop = true
-		}
+		t.view.fit(a, maxW, maxH)
+		v.Title = t.view.title(a, maxW, maxH)
+		s := t.view.scale()
+		gw, gh := t.view.glyphSize()
 
 		var b bytes.Buffer
 
-		for i, l := range a.Entities {
-			//discard the data outside the view area
-			if i >= maxH {
-				break
-			}
+		for i := 0; i < maxH && t.view.y+i*gh < a.Height; i++ {
+			y := t.view.y + i*gh
 			//line feed char
 			if i != 0 {
 				b.WriteByte(10)
 			}
-			if crop && i == (maxH-1) {
-				b.WriteString(aurora.Red("The field size is larger than the viewing area").BgBlack().String())
-				break
-			}
-			for j, e := range l {
-				if j >= maxW {
-					break
-				}
-				if e {
-					b.WriteString(t.liveFiller)
-				} else {
-					b.WriteString(t.deadFiller)
-				}
+			for j := 0; j < maxW && t.view.x+j*gw < a.Width; j++ {
+				b.WriteString(t.glyph(a, act, t.view.x+j*gw, y, s))
 			}
 		}
 		_, _ = fmt.Fprint(v, b.String())
@@ -182,8 +473,41 @@ func (t *ConsoleUI) renderStatus() {
 			v.Clear()
 			_, _ = fmt.Fprintln(v, t.renderProp("Step", "%v", s.IterationNum))
 			_, _ = fmt.Fprintln(v, t.renderProp("Live Cells", "%v", s.LiveCells))
+			if t.u.Options().TrackActivity {
+				_, _ = fmt.Fprintln(v, t.renderProp("Births/Deaths", "%v/%v", s.Births, s.Deaths))
+			}
 			_, _ = fmt.Fprintln(v, t.renderProp("Evaluation time", "%v", s.IterationTime.Round(time.Microsecond)))
 			_, _ = fmt.Fprintln(v, t.renderProp("Mode", "%v", runningStateDescr[s.RunningMode]))
+			if t.u.Options().History > 0 {
+				_, _ = fmt.Fprintln(v, t.renderProp("Undo/Redo", "%v/%v", s.Undo, s.Redo))
+			}
+			if t.editor.on {
+				_, _ = fmt.Fprintln(v, t.renderProp("Editor", "%v", t.editorStatus()))
+			}
+			if c := t.editor.clipboard; c != nil {
+				_, _ = fmt.Fprintln(v, t.renderProp("Clipboard", "%v", c))
+			}
+			if t.escaped != "" {
+				_, _ = fmt.Fprintln(v, t.renderProp("Escaped", "%v", t.escaped))
+			}
+			if s.RunningMode == universe.RunningStateFinished && s.FinishReason != universe.FinishReasonNone {
+				if s.Err != nil {
+					_, _ = fmt.Fprintln(v, t.renderProp("Finished", "%v", aurora.Red(s.FinishDescr())))
+				} else {
+					_, _ = fmt.Fprintln(v, t.renderProp("Finished", "%v", s.FinishDescr()))
+				}
+			}
+			if t.message != "" {
+				_, _ = fmt.Fprintln(v, t.renderProp("Message", "%v", t.message))
+			}
+			propNames := make([]string, 0, len(s.Details))
+			for k := range s.Details {
+				propNames = append(propNames, k)
+			}
+			sort.Strings(propNames)
+			for _, propName := range propNames {
+				_, _ = fmt.Fprintln(v, t.renderProp(propName, "%v", s.Details[propName]))
+			}
 		}
 		return nil
 	})
@@ -199,6 +523,9 @@ func (t *ConsoleUI) renderConfiguration() {
 			_, _ = fmt.Fprintln(v, t.renderProp("Dimension", "%v x %v", c.Width, c.Height))
 			_, _ = fmt.Fprintln(v, t.renderProp("Interval", "%v", c.Interval))
 			_, _ = fmt.Fprintln(v, t.renderProp("Iterations", "%v steps", c.MaxSteps))
+			_, _ = fmt.Fprintln(v, t.renderProp("Rule", "%v", c.Rule))
+			_, _ = fmt.Fprintln(v, t.renderProp("Topology", "%v", c.Topology))
+			_, _ = fmt.Fprintln(v, t.renderProp("History", "%v states", c.History))
 			propNames := make([]string, 0, len(c.Advanced))
 			for k := range c.Advanced {
 				propNames = append(propNames, k)
@@ -234,6 +561,7 @@ func (t *ConsoleUI) layout(g *gocui.Gui) error {
 		_ = g.DeleteView("configuration")
 		_ = g.DeleteView("status")
 		_ = g.DeleteView("battlefield")
+		_ = g.DeleteView("history")
 		return nil
 
 	} else {
@@ -262,36 +590,111 @@ func (t *ConsoleUI) layout(g *gocui.Gui) error {
 		t.renderStatus()
 	}
 
-	if v, err := g.SetView("battlefield", leftColumnWidth+1, 3, maxX-1, maxY-5); err != nil {
+	if v, err := g.SetView("battlefield", leftColumnWidth+1, 3, maxX-1, maxY-5-t.graphHeight(maxY)); err != nil {
 		if err != gocui.ErrUnknownView || v == nil {
 			return err
 		}
 		v.Title = "Battle Field"
 		v.Frame = true
+		if g.CurrentView() == nil {
+			//the battle field keybindings (panning, zoom) are active in the current view
+			_, _ = g.SetCurrentView("battlefield")
+		}
+		t.fieldW, t.fieldH = v.Size()
 		t.renderField(t.u.Area())
-	} else {
+	} else if w, h := v.Size(); w != t.fieldW || h != t.fieldH {
+		//the terminal is resized, the visible part of the field is changed
+		//the layout is called on every gocui loop, the field isn't redrawn if the size is the same
+		t.fieldW, t.fieldH = w, h
 		t.renderField(t.u.Area())
 	}
 
-	if v, err := g.SetView("help", -1, maxY-5, maxX, maxY-3); err != nil {
+	if err := t.graphLayout(g, leftColumnWidth+1, maxX-1, maxY); err != nil {
+		return err
+	}
+
+	if err := t.pickerLayout(g, maxX, maxY); err != nil {
+		return err
+	}
+
+	if v, err := g.SetView("help", -1, maxY-5, maxX, maxY); err != nil {
 		if err != gocui.ErrUnknownView || v == nil {
 			return err
 		}
 		v.Frame = false
+		v.Wrap = true
+		t.renderHelp()
+	}
+
+	return nil
+}
+
+//renderHelp renders the keybindings help, the edit mode keys are shown in the edit mode
+//calls by the gocui goroutine
+func (t *ConsoleUI) renderHelp() {
+	v, err := t.g.View("help")
+	if err != nil {
+		return
+	}
+	v.Clear()
+	title, keys := "KEYBINDINGS: ", t.k
+	if t.editor.on {
+		title = "EDIT MODE: "
+		keys = append([]keyBindings{
+			{name: "E", descr: "Leave"},
+			{name: "←↑→↓", descr: "Cursor"},
+			{name: "MOUSE", descr: "Draw (move the cursor when zoomed out), right button to erase"},
+		}, t.editKeys...)
+	}
+	b := bytes.Buffer{}
+	b.WriteString(title)
+	for _, k := range keys {
+		if k.name == "" {
+			//the additional key of the previous binding
+			continue
+		}
+		if b.Len() > len(title) {
+			b.WriteString(", ")
+		}
+		b.WriteString(aurora.Green(k.name).String())
+		b.WriteString(": ")
+		b.WriteString(k.descr)
+	}
+	_, _ = fmt.Fprintln(v, b.String())
+}
+
+//pickerLayout creates the pattern picker panel over the battle field when the picker is shown, deletes it otherwise
+func (t *ConsoleUI) pickerLayout(g *gocui.Gui, maxX int, maxY int) error {
+	if !t.picker {
+		if err := g.DeleteView("patterns"); err == nil {
+			_, _ = g.SetCurrentView("battlefield")
+		}
+		return nil
+	}
+	pickerWidth := 40
+	v, err := g.SetView("patterns", maxX-pickerWidth-1, 3, maxX-1, maxY-5)
+	if err != nil {
+		if err != gocui.ErrUnknownView || v == nil {
+			return err
+		}
 		b := bytes.Buffer{}
-		b.WriteString("KEYBINDINGS: ")
-		for i, k := range t.k {
+		for i, k := range t.pickerKeys {
 			if i != 0 {
 				b.WriteString(", ")
 			}
-			b.WriteString(aurora.Green(k.name).String())
-			b.WriteString(": ")
-			b.WriteString(k.descr)
+			b.WriteString(k.name + ": " + k.descr)
+		}
+		v.Title = "Patterns (" + b.String() + ")"
+		v.Frame = true
+		v.Highlight = true
+		v.SelBgColor = gocui.ColorGreen
+		v.SelFgColor = gocui.ColorBlack
+		for _, tmpl := range t.u.Templates() {
+			_, _ = fmt.Fprintf(v, "%-14v %v\n", tmpl.Name, aurora.Colorize(tmpl.Category, aurora.BlueFg))
 		}
-		_, _ = fmt.Fprintln(v, b.String())
 	}
-
-	return nil
+	_, err = g.SetCurrentView("patterns")
+	return err
 }
 
 //headerLayout creates the window header with center positioning message
@@ -345,13 +748,125 @@ func (t *ConsoleUI) cmdClear(_ *gocui.View) error {
 
 //cmdSettleWithRandom calls by gocui key handler and calls the Settle With Random Cells command in the Universe
 func (t *ConsoleUI) cmdSettleWithRandom(_ *gocui.View) error {
-	t.u.SettleWithRandomData()
+	if err := t.u.SettleWithRandomData(); err != nil {
+		t.showMessage("", err)
+	}
+	return nil
+}
+
+//cmdUndo calls by gocui key handler and calls the Undo command in the Universe
+func (t *ConsoleUI) cmdUndo(_ *gocui.View) error {
+	t.u.Undo()
 	return nil
 }
 
-//cmdMouseClick calls by gocui mouse button is clicked and calls Inverse command fot the cell in the Universe
-func (t *ConsoleUI) cmdMouseClick(v *gocui.View) error {
-	cx, cy := v.Cursor()
-	t.u.InverseCell(cx, cy)
+//cmdRedo calls by gocui key handler and calls the Redo command in the Universe
+func (t *ConsoleUI) cmdRedo(_ *gocui.View) error {
+	t.u.Redo()
+	return nil
+}
+
+//cmdRewind calls by gocui key handler and calls the Rewind command in the Universe
+func (t *ConsoleUI) cmdRewind(_ *gocui.View) error {
+	t.u.Rewind(rewindSteps)
+	return nil
+}
+
+//cmdSaveSnapshot calls by gocui key handler and saves the universe snapshot to the snapshot file
+func (t *ConsoleUI) cmdSaveSnapshot(_ *gocui.View) error {
+	f, err := os.Create(t.snapshotFile)
+	if err == nil {
+		err = t.u.SaveSnapshot(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	t.showMessage("snapshot saved to "+t.snapshotFile, err)
+	return nil
+}
+
+//cmdLoadSnapshot calls by gocui key handler and restores the universe from the snapshot file
+func (t *ConsoleUI) cmdLoadSnapshot(_ *gocui.View) error {
+	f, err := os.Open(t.snapshotFile)
+	if err == nil {
+		err = t.u.LoadSnapshot(f)
+		_ = f.Close()
+	}
+	t.showMessage("snapshot loaded from "+t.snapshotFile, err)
+	return nil
+}
+
+//showMessage shows the result of the command in the status panel
+func (t *ConsoleUI) showMessage(message string, err error) {
+	if err != nil {
+		message = aurora.Red(err.Error()).String()
+	}
+	t.message = message
+	t.renderStatus()
+}
+
+//cmdPicker calls by gocui key handler and shows or hides the pattern picker panel
+func (t *ConsoleUI) cmdPicker(_ *gocui.View) error {
+	t.picker = !t.picker
+	return nil
+}
+
+//cmdPickerMove returns the gocui key handler moving the picker selection by delta lines
+func (t *ConsoleUI) cmdPickerMove(delta int) func(v *gocui.View) error {
+	return func(v *gocui.View) error {
+		_, cy := v.Cursor()
+		_, oy := v.Origin()
+		if n := oy + cy + delta; n < 0 || n >= len(t.u.Templates()) {
+			return nil
+		}
+		if err := v.SetCursor(0, cy+delta); err != nil {
+			//the cursor is at the view edge, scroll the view
+			return v.SetOrigin(0, oy+delta)
+		}
+		return nil
+	}
+}
+
+//cmdPickerSelect calls by gocui key handler, selects the template to stamp by the next click on the field
+func (t *ConsoleUI) cmdPickerSelect(v *gocui.View) error {
+	_, cy := v.Cursor()
+	_, oy := v.Origin()
+	templates := t.u.Templates()
+	if oy+cy >= len(templates) {
+		return nil
+	}
+	tmpl := templates[oy+cy]
+	t.editor.clipboard = newClipboard(tmpl.Name, tmpl.Coordinates)
+	t.stamp = true
+	t.picker = false
+	t.showMessage(fmt.Sprintf("click the field to place %v", tmpl.Name), nil)
+	return nil
+}
+
+//cmdMouseClick calls by gocui mouse button is released and inverts the cell in the Universe
+//stamps the template selected in the picker instead if any, the view position is mapped to the field under any pan and zoom
+//the glyph covering several cells is zoomed in instead of inverting its hidden top-left cell
+//the dragging pans the view instead, the edit mode draws on the press
+func (t *ConsoleUI) cmdMouseClick(_ *gocui.View) error {
+	if !t.view.pressed || t.view.dragged || t.editor.on {
+		t.view.pressed = false
+		return nil
+	}
+	t.view.pressed = false
+	cx, cy := t.view.cell(t.view.pressX, t.view.pressY)
+	if a := t.u.Area(); cx >= a.Width || cy >= a.Height {
+		return nil
+	}
+	if t.stamp {
+		t.stampClipboard(cx, cy)
+		return nil
+	}
+	if !t.view.single() {
+		t.view.follow = false
+		t.view.zoomIn(t.view.pressX, t.view.pressY)
+		t.renderField(t.u.Area())
+		return nil
+	}
+	t.u.Edit(universe.Edit{Invert: [][]int{{cx, cy}}})
 	return nil
 }
@@ -0,0 +1,472 @@
+package view
+
+import (
+	"fmt"
+	"github.com/jroimartin/gocui"
+	"github.com/logrusorgru/aurora"
+	"simlife/src/universe"
+)
+
+/*
+	The field editor
+	the edit mode moves the keyboard cursor by the arrow keys instead of the panning, the brush draws or erases
+	the square of the cells at the cursor, the mouse dragging draws (the left button) or erases (the right button)
+	the rectangular selection is spanned from the anchor to the cursor, it's copied or cut to the clipboard,
+	rotated, flipped or filled with the random soup in place, the clipboard is pasted at the cursor replacing the cells,
+	the rotation and the flips are applied to the clipboard if nothing is selected
+	the template selected in the pattern picker is loaded to the clipboard, it's stamped by the next click on the field
+	all edits are done by universe.Edit, so they are applied between the steps of the running simulation,
+	the segments of one mouse stroke are joined to one undo step
+	the editor is used by the gocui goroutine only
+*/
+
+const maxBrush = 9 //the maximum size of the brush
+
+//clipboard is the rectangle of the cells to paste
+type clipboard struct {
+	name   string
+	width  int
+	height int
+	cells  [][]int //the live cells relative to the top-left corner
+}
+
+type editor struct {
+	on        bool
+	x         int //the cursor field position
+	y         int
+	brush     int  //the size of the square brush centred at the cursor
+	selecting bool //the selection is spanned from the anchor to the cursor
+	anchorX   int
+	anchorY   int
+	selection universe.Region //the selected region, the empty region if nothing is selected
+	clipboard *clipboard
+	erasing   bool //the mouse dragging erases the cells
+	lastX     int  //the last cell drawn by the mouse dragging
+	lastY     int
+}
+
+//newClipboard creates the clipboard with the cells normalized to their bounding box
+func newClipboard(name string, cells [][]int) *clipboard {
+	c := &clipboard{name: name}
+	if len(cells) == 0 {
+		return c
+	}
+	minX, minY, maxX, maxY := cells[0][0], cells[0][1], cells[0][0], cells[0][1]
+	for _, p := range cells {
+		minX, minY = min(minX, p[0]), min(minY, p[1])
+		maxX, maxY = max(maxX, p[0]), max(maxY, p[1])
+	}
+	c.width, c.height = maxX-minX+1, maxY-minY+1
+	for _, p := range cells {
+		c.cells = append(c.cells, []int{p[0] - minX, p[1] - minY})
+	}
+	return c
+}
+
+//regionClipboard creates the clipboard with the live cells of the area region
+func regionClipboard(a universe.Area, r universe.Region) *clipboard {
+	c := &clipboard{name: "selection", width: r.Width, height: r.Height}
+	for y := r.Y; y < r.Y+r.Height; y++ {
+		for x := r.X; x < r.X+r.Width; x++ {
+			if a.Entities[y][x] {
+				c.cells = append(c.cells, []int{x - r.X, y - r.Y})
+			}
+		}
+	}
+	return c
+}
+
+//rotate returns the clipboard rotated clockwise
+func (c *clipboard) rotate() *clipboard {
+	r := &clipboard{name: c.name, width: c.height, height: c.width}
+	for _, p := range c.cells {
+		r.cells = append(r.cells, []int{c.height - 1 - p[1], p[0]})
+	}
+	return r
+}
+
+//flip returns the clipboard mirrored horizontally (left-right) or vertically (top-bottom)
+func (c *clipboard) flip(horizontal bool) *clipboard {
+	r := &clipboard{name: c.name, width: c.width, height: c.height}
+	for _, p := range c.cells {
+		if horizontal {
+			r.cells = append(r.cells, []int{c.width - 1 - p[0], p[1]})
+		} else {
+			r.cells = append(r.cells, []int{p[0], c.height - 1 - p[1]})
+		}
+	}
+	return r
+}
+
+//at returns the field coordinates of the cells placed with the top-left corner at x,y
+func (c *clipboard) at(x int, y int) [][]int {
+	cells := make([][]int, len(c.cells))
+	for i, p := range c.cells {
+		cells[i] = []int{p[0] + x, p[1] + y}
+	}
+	return cells
+}
+
+func (c *clipboard) String() string {
+	return fmt.Sprintf("%v %vx%v", c.name, c.width, c.height)
+}
+
+//brushCells returns the cells of the brush moved along the line from x0,y0 to x1,y1
+func (e *editor) brushCells(x0 int, y0 int, x1 int, y1 int) [][]int {
+	cells := [][]int{}
+	seen := map[[2]int]bool{}
+	for _, p := range line(x0, y0, x1, y1) {
+		for by := 0; by < e.brush; by++ {
+			for bx := 0; bx < e.brush; bx++ {
+				c := [2]int{p[0] + bx - (e.brush-1)/2, p[1] + by - (e.brush-1)/2}
+				if !seen[c] {
+					seen[c] = true
+					cells = append(cells, []int{c[0], c[1]})
+				}
+			}
+		}
+	}
+	return cells
+}
+
+//line returns the cells of the line from x0,y0 to x1,y1 (the Bresenham's algorithm)
+func line(x0 int, y0 int, x1 int, y1 int) [][]int {
+	dx, dy := x1-x0, y1-y0
+	sx, sy := 1, 1
+	if dx < 0 {
+		dx, sx = -dx, -1
+	}
+	if dy < 0 {
+		dy, sy = -dy, -1
+	}
+	cells := [][]int{}
+	for err := dx - dy; ; {
+		cells = append(cells, []int{x0, y0})
+		if x0 == x1 && y0 == y1 {
+			return cells
+		}
+		if e2 := 2 * err; e2 > -dy {
+			err -= dy
+			x0 += sx
+		} else {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+//editorColour returns the colour of the glyph covering w x h cells from x,y: the cursor or the selection, 0 otherwise
+func (t *ConsoleUI) editorColour(x int, y int, w int, h int) aurora.Color {
+	e := &t.editor
+	if !e.on {
+		return 0
+	}
+	if e.x >= x && e.x < x+w && e.y >= y && e.y < y+h {
+		return aurora.YellowFg | aurora.ReverseFm
+	}
+	s := e.selection
+	if s.Width > 0 && x < s.X+s.Width && s.X < x+w && y < s.Y+s.Height && s.Y < y+h {
+		return aurora.WhiteFg | aurora.BlueBg
+	}
+	return 0
+}
+
+//editorStatus returns the editor state description for the status panel
+func (t *ConsoleUI) editorStatus() string {
+	e := &t.editor
+	s := fmt.Sprintf("%v,%v brush %v", e.x, e.y, e.brush)
+	if e.selection.Width > 0 {
+		s += fmt.Sprintf(", selected %vx%v", e.selection.Width, e.selection.Height)
+	}
+	return s
+}
+
+//edit applies the edit to the universe, the cells outside the field are reported
+func (t *ConsoleUI) edit(e universe.Edit, message string) {
+	a := t.u.Area()
+	dropped := 0
+	for _, c := range e.Live {
+		if c[0] < 0 || c[1] < 0 || c[0] >= a.Width || c[1] >= a.Height {
+			dropped++
+		}
+	}
+	t.u.Edit(e)
+	if dropped > 0 {
+		message = fmt.Sprintf("%v, %v cells outside the field are dropped", message, dropped)
+	}
+	if message != "" {
+		t.showMessage(message, nil)
+	}
+}
+
+//cmdEdit calls by gocui key handler and switches the edit mode, the cursor is placed to the centre of the visible field
+func (t *ConsoleUI) cmdEdit(_ *gocui.View) error {
+	e := &t.editor
+	e.on = !e.on
+	if e.on {
+		gw, gh := t.view.glyphSize()
+		a := t.u.Area()
+		e.x = t.view.x + min(t.fieldW*gw, a.Width-t.view.x)/2
+		e.y = t.view.y + min(t.fieldH*gh, a.Height-t.view.y)/2
+		if e.brush == 0 {
+			e.brush = 1
+		}
+	}
+	e.selecting = false
+	e.selection = universe.Region{}
+	t.renderHelp()
+	t.renderStatus()
+	t.renderField(t.u.Area())
+	return nil
+}
+
+//cmdMove returns the gocui key handler moving the cursor in the edit mode or panning the view otherwise
+func (t *ConsoleUI) cmdMove(dx int, dy int) func(v *gocui.View) error {
+	pan := t.cmdPan(dx, dy)
+	return func(v *gocui.View) error {
+		if !t.editor.on {
+			return pan(v)
+		}
+		a := t.u.Area()
+		t.moveCursor(clamp(t.editor.x+dx, 0, a.Width-1), clamp(t.editor.y+dy, 0, a.Height-1), v)
+		t.renderStatus()
+		t.renderField(a)
+		return nil
+	}
+}
+
+//moveCursor moves the cursor to the field position, spans the selection and pans the view to keep the cursor visible
+func (t *ConsoleUI) moveCursor(x int, y int, v *gocui.View) {
+	e := &t.editor
+	e.x, e.y = x, y
+	if e.selecting {
+		e.selection = universe.Region{X: min(e.anchorX, x), Y: min(e.anchorY, y), Width: abs(x-e.anchorX) + 1, Height: abs(y-e.anchorY) + 1}
+	}
+	w, h := v.Size()
+	gw, gh := t.view.glyphSize()
+	if x < t.view.x || x >= t.view.x+w*gw || y < t.view.y || y >= t.view.y+h*gh {
+		t.view.follow = false
+		t.view.x, t.view.y = x-w*gw/2, y-h*gh/2
+	}
+}
+
+//cmdDraw returns the gocui key handler drawing (live is true) or erasing the brush at the cursor
+func (t *ConsoleUI) cmdDraw(live bool) func(v *gocui.View) error {
+	return func(_ *gocui.View) error {
+		e := &t.editor
+		if !e.on {
+			return nil
+		}
+		cells := e.brushCells(e.x, e.y, e.x, e.y)
+		if live {
+			t.edit(universe.Edit{Live: cells}, "")
+		} else {
+			t.edit(universe.Edit{Dead: cells}, "")
+		}
+		return nil
+	}
+}
+
+//cmdBrush returns the gocui key handler changing the brush size by delta
+func (t *ConsoleUI) cmdBrush(delta int) func(v *gocui.View) error {
+	return func(_ *gocui.View) error {
+		if !t.editor.on {
+			return nil
+		}
+		t.editor.brush = clamp(t.editor.brush+delta, 1, maxBrush)
+		t.renderStatus()
+		return nil
+	}
+}
+
+//cmdSelect calls by gocui key handler, starts the selection at the cursor or finishes it
+func (t *ConsoleUI) cmdSelect(_ *gocui.View) error {
+	e := &t.editor
+	if !e.on {
+		return nil
+	}
+	e.selecting = !e.selecting
+	if e.selecting {
+		e.anchorX, e.anchorY = e.x, e.y
+		e.selection = universe.Region{X: e.x, Y: e.y, Width: 1, Height: 1}
+	}
+	t.renderStatus()
+	t.renderField(t.u.Area())
+	return nil
+}
+
+//cmdDeselect calls by gocui key handler, drops the selection and the template stamp
+func (t *ConsoleUI) cmdDeselect(_ *gocui.View) error {
+	if !t.editor.on {
+		return nil
+	}
+	t.editor.selecting = false
+	t.editor.selection = universe.Region{}
+	t.stamp = false
+	t.renderStatus()
+	t.renderField(t.u.Area())
+	return nil
+}
+
+//cmdCopy returns the gocui key handler copying (or cutting if cut is true) the selection to the clipboard
+func (t *ConsoleUI) cmdCopy(cut bool) func(v *gocui.View) error {
+	return func(_ *gocui.View) error {
+		e := &t.editor
+		if !e.on {
+			return nil
+		}
+		if e.selection.Width == 0 {
+			t.showMessage("select the region first", nil)
+			return nil
+		}
+		e.clipboard = regionClipboard(t.u.Area(), e.selection)
+		if cut {
+			t.edit(universe.Edit{Clear: e.selection}, fmt.Sprintf("%v cut", e.clipboard))
+		} else {
+			t.showMessage(fmt.Sprintf("%v copied", e.clipboard), nil)
+		}
+		return nil
+	}
+}
+
+//cmdPaste calls by gocui key handler, pastes the clipboard at the cursor replacing the cells
+func (t *ConsoleUI) cmdPaste(_ *gocui.View) error {
+	e := &t.editor
+	if !e.on {
+		return nil
+	}
+	c := e.clipboard
+	if c == nil {
+		t.showMessage("the clipboard is empty", nil)
+		return nil
+	}
+	t.edit(universe.Edit{
+		Clear: universe.Region{X: e.x, Y: e.y, Width: c.width, Height: c.height},
+		Live:  c.at(e.x, e.y),
+	}, fmt.Sprintf("%v pasted at %v,%v", c, e.x, e.y))
+	return nil
+}
+
+//cmdTransform returns the gocui key handler rotating or flipping the selection in place or the clipboard
+func (t *ConsoleUI) cmdTransform(name string, transform func(c *clipboard) *clipboard) func(v *gocui.View) error {
+	return func(_ *gocui.View) error {
+		e := &t.editor
+		if !e.on {
+			return nil
+		}
+		if s := e.selection; s.Width > 0 {
+			c := transform(regionClipboard(t.u.Area(), s))
+			e.selecting = false
+			e.selection = universe.Region{X: s.X, Y: s.Y, Width: c.width, Height: c.height}
+			//the selection is cleared, the transformed cells replace the cells of the new selection
+			dead := [][]int{}
+			for y := s.Y; y < s.Y+c.height; y++ {
+				for x := s.X; x < s.X+c.width; x++ {
+					dead = append(dead, []int{x, y})
+				}
+			}
+			t.edit(universe.Edit{Clear: s, Dead: dead, Live: c.at(s.X, s.Y)}, "the selection "+name)
+			return nil
+		}
+		if e.clipboard == nil {
+			t.showMessage("select the region first", nil)
+			return nil
+		}
+		e.clipboard = transform(e.clipboard)
+		t.showMessage(fmt.Sprintf("%v %v", e.clipboard, name), nil)
+		return nil
+	}
+}
+
+//cmdFillRandom calls by gocui key handler, fills the selection with the random soup
+func (t *ConsoleUI) cmdFillRandom(_ *gocui.View) error {
+	e := &t.editor
+	if !e.on {
+		return nil
+	}
+	if e.selection.Width == 0 {
+		t.showMessage("select the region first", nil)
+		return nil
+	}
+	t.edit(universe.Edit{Clear: e.selection, Random: true}, "the selection filled with the random soup")
+	return nil
+}
+
+//editorPress starts the drawing (or erasing) by the mouse at the view position, stamps the template instead if any
+//the glyph covering several cells only moves the cursor, the keys draw the cells under the glyph then
+func (t *ConsoleUI) editorPress(v *gocui.View, erase bool) error {
+	vx, vy := v.Cursor()
+	x, y := t.view.cell(vx, vy)
+	a := t.u.Area()
+	if x >= a.Width || y >= a.Height {
+		return nil
+	}
+	e := &t.editor
+	e.x, e.y = x, y
+	e.selecting = false
+	if t.stamp && !erase {
+		t.stampClipboard(x, y)
+		return nil
+	}
+	if !t.view.single() {
+		t.renderStatus()
+		t.renderField(a)
+		return nil
+	}
+	t.view.pressed = true
+	e.erasing = erase
+	e.lastX, e.lastY = x, y
+	t.drawLine(x, y, false)
+	return nil
+}
+
+//editorDrag draws (or erases) the line from the last drawn cell to the mouse position
+func (t *ConsoleUI) editorDrag(v *gocui.View) error {
+	if !t.view.pressed {
+		return nil
+	}
+	vx, vy := v.Cursor()
+	x, y := t.view.cell(vx, vy)
+	a := t.u.Area()
+	x, y = clamp(x, 0, a.Width-1), clamp(y, 0, a.Height-1)
+	if x == t.editor.lastX && y == t.editor.lastY {
+		return nil
+	}
+	t.editor.x, t.editor.y = x, y
+	t.drawLine(x, y, true)
+	return nil
+}
+
+//drawLine draws (or erases) the brush along the line from the last drawn cell to x,y, join continues the stroke undo step
+func (t *ConsoleUI) drawLine(x int, y int, join bool) {
+	e := &t.editor
+	cells := e.brushCells(e.lastX, e.lastY, x, y)
+	e.lastX, e.lastY = x, y
+	if e.erasing {
+		t.edit(universe.Edit{Dead: cells, Join: join}, "")
+	} else {
+		t.edit(universe.Edit{Live: cells, Join: join}, "")
+	}
+}
+
+//stampClipboard stamps the template from the clipboard at x,y adding the cells to the field
+func (t *ConsoleUI) stampClipboard(x int, y int) {
+	c := t.editor.clipboard
+	t.stamp = false
+	t.edit(universe.Edit{Live: c.at(x, y)}, fmt.Sprintf("%v placed at %v,%v", c.name, x, y))
+}
+
+//cmdMouseErase calls by gocui when the right mouse button is pressed on the battle field, starts the erasing in the edit mode
+func (t *ConsoleUI) cmdMouseErase(v *gocui.View) error {
+	if !t.editor.on {
+		return nil
+	}
+	return t.editorPress(v, true)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
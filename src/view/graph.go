@@ -0,0 +1,169 @@
+package view
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/jroimartin/gocui"
+	"github.com/logrusorgru/aurora"
+	"simlife/src/universe"
+	"time"
+)
+
+/*
+	The history graph panel
+	the scrolling sparklines of the population, the births and deaths (with the activity tracking)
+	and the iteration time over the last generations, the samples are taken from the status on every refresh
+	the sparkline is scaled from the minimum to the maximum of the visible samples, the current value is shown in the label
+	the graph is used by the gocui goroutine only (the samples are added in the Update functions)
+*/
+
+const (
+	graphHistory          = 1024 //the number of the last generations kept for the graph
+	graphLabelWidth       = 22
+	minGraphWindowHeight  = 30 //the graph panel is shown if the terminal is high enough
+	graphRowsWithActivity = 4
+	graphRows             = 2
+)
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+//sample is the status of the generation kept for the graph
+type sample struct {
+	generation int
+	population int
+	births     int
+	deaths     int
+	time       time.Duration
+}
+
+type graph struct {
+	hidden  bool //the graph panel is hidden by the keybinding
+	samples []sample
+	width   int //the graph view width the graph is drawn for
+}
+
+//add adds the sample of the status, the samples after the generation are dropped if the generation goes back (undo, clear)
+//the sample of the same generation is replaced (the area is edited)
+func (gr *graph) add(st universe.Status) {
+	s := sample{st.IterationNum, st.LiveCells, st.Births, st.Deaths, st.IterationTime}
+	n := len(gr.samples)
+	for n > 0 && gr.samples[n-1].generation >= s.generation {
+		n--
+	}
+	gr.samples = append(gr.samples[:n], s)
+	if len(gr.samples) > graphHistory {
+		gr.samples = append(gr.samples[:0], gr.samples[len(gr.samples)-graphHistory:]...)
+	}
+}
+
+//sparkline returns the sparkline of the last width values
+func sparkline(values []int, width int) string {
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	line := make([]rune, len(values))
+	for i, v := range values {
+		level := 0
+		if hi > lo {
+			level = (v - lo) * (len(sparks) - 1) / (hi - lo)
+		} else if hi > 0 {
+			level = len(sparks) / 2
+		}
+		line[i] = sparks[level]
+	}
+	return string(line)
+}
+
+//graphHeight returns the height of the graph panel with the frame, 0 if the panel isn't shown
+func (t *ConsoleUI) graphHeight(maxY int) int {
+	if t.graph.hidden || maxY < minGraphWindowHeight {
+		return 0
+	}
+	if t.u.Options().TrackActivity {
+		return graphRowsWithActivity + 2
+	}
+	return graphRows + 2
+}
+
+//graphLayout creates the graph panel under the battle field in the x0-x1 columns, deletes it if the panel isn't shown
+func (t *ConsoleUI) graphLayout(g *gocui.Gui, x0 int, x1 int, maxY int) error {
+	height := t.graphHeight(maxY)
+	if height == 0 {
+		_ = g.DeleteView("history")
+		return nil
+	}
+	v, err := g.SetView("history", x0, maxY-5-height+1, x1, maxY-5)
+	if err != nil {
+		if err != gocui.ErrUnknownView || v == nil {
+			return err
+		}
+		v.Frame = true
+		t.graph.width = 0
+	}
+	if w, _ := v.Size(); w != t.graph.width {
+		//the panel is created or resized, the sparklines are redrawn for the new width
+		t.graph.width = w
+		t.drawGraph(v)
+	}
+	return nil
+}
+
+//renderGraph adds the current status to the graph and renders the graph panel
+//the status is taken by the Update function, the functions can be called out of order, so the samples are kept in order
+func (t *ConsoleUI) renderGraph() {
+	t.g.Update(func(g *gocui.Gui) error {
+		t.graph.add(t.u.Status())
+		if v, e := g.View("history"); e == nil {
+			t.drawGraph(v)
+		}
+		return nil
+	})
+}
+
+//drawGraph draws the sparklines of the graph samples
+func (t *ConsoleUI) drawGraph(v *gocui.View) {
+	v.Clear()
+	w, _ := v.Size()
+	width := max(w-graphLabelWidth, 0)
+	n := min(len(t.graph.samples), width)
+	v.Title = fmt.Sprintf("History (last %v steps)", n)
+
+	samples := t.graph.samples[len(t.graph.samples)-n:]
+	series := func(f func(s sample) int) []int {
+		values := make([]int, len(samples))
+		for i, s := range samples {
+			values[i] = f(s)
+		}
+		return values
+	}
+	var last sample
+	if n > 0 {
+		last = samples[n-1]
+	}
+	b := bytes.Buffer{}
+	row := func(name string, value interface{}, values []int) {
+		b.WriteString(fmt.Sprintf(" %s%*v ", aurora.Green(name), graphLabelWidth-len(name)-2, value))
+		b.WriteString(sparkline(values, width))
+		b.WriteByte('\n')
+	}
+	row("Population", last.population, series(func(s sample) int { return s.population }))
+	if t.u.Options().TrackActivity {
+		row("Births", last.births, series(func(s sample) int { return s.births }))
+		row("Deaths", last.deaths, series(func(s sample) int { return s.deaths }))
+	}
+	row("Step time", last.time.Round(time.Microsecond), series(func(s sample) int { return int(s.time / time.Microsecond) }))
+	_, _ = fmt.Fprint(v, b.String())
+}
+
+//cmdGraph calls by gocui key handler and shows or hides the graph panel
+func (t *ConsoleUI) cmdGraph(_ *gocui.View) error {
+	t.graph.hidden = !t.graph.hidden
+	return nil
+}
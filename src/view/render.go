@@ -0,0 +1,162 @@
+package view
+
+import (
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"simlife/src/universe"
+	"strings"
+)
+
+/*
+	The battle field render modes
+	the block mode draws one cell by one glyph, the high density modes pack several cells to one glyph:
+	the half blocks draw two cells vertically, the Braille patterns draw 2 x 4 cells,
+	so the large field (400 x 200) fits the usual terminal
+	the zoomed out view aggregates N x N cells to one "pixel" of the glyph, the pixel is set if any of the cells is alive
+*/
+
+//RenderMode is the way the battle field cells are drawn by the glyphs
+type RenderMode int
+
+const (
+	RenderBlock   RenderMode = iota //one cell per glyph
+	RenderHalf                      //two cells per glyph vertically, the Unicode half blocks
+	RenderBraille                   //2 x 4 cells per glyph, the Unicode Braille patterns
+)
+
+var renderModeNames = map[RenderMode]string{
+	RenderBlock:   "block",
+	RenderHalf:    "half",
+	RenderBraille: "braille",
+}
+
+//brailleDots are the dot bits of the Braille pattern by the pixel position in the glyph [y][x]
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+const brailleBlank = 0x2800 //the Braille pattern without dots
+
+//RenderModeNames returns the names of the render modes
+func RenderModeNames() []string {
+	names := make([]string, 0, len(renderModeNames))
+	for m := RenderBlock; m <= RenderBraille; m++ {
+		names = append(names, renderModeNames[m])
+	}
+	return names
+}
+
+//ParseRenderMode returns the render mode by its name
+func ParseRenderMode(s string) (RenderMode, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for m, n := range renderModeNames {
+		if n == name {
+			return m, nil
+		}
+	}
+	return RenderBlock, fmt.Errorf("unknown render mode %q, expected one of [%s]", s, strings.Join(RenderModeNames(), "|"))
+}
+
+//String returns the render mode name
+func (m RenderMode) String() string {
+	if n, ok := renderModeNames[m]; ok {
+		return n
+	}
+	return fmt.Sprintf("RenderMode(%d)", int(m))
+}
+
+//pixels returns the number of the pixels of one glyph horizontally and vertically
+func (m RenderMode) pixels() (int, int) {
+	switch m {
+	case RenderHalf:
+		return 1, 2
+	case RenderBraille:
+		return 2, 4
+	default:
+		return 1, 1
+	}
+}
+
+//next returns the next render mode to switch by the keybinding
+func (m RenderMode) next() RenderMode {
+	if m >= RenderBraille {
+		return RenderBlock
+	}
+	return m + 1
+}
+
+//glyph returns the glyph with the top-left cell x,y, every pixel of the glyph aggregates s x s cells
+//the glyph is coloured by the editor cursor and selection or by the cells activity if the activity colouring is on
+func (t *ConsoleUI) glyph(a universe.Area, act *universe.Activity, x int, y int, s int) string {
+	g := t.rawGlyph(a, x, y, s)
+	gw, gh := t.view.glyphSize()
+	if c := t.editorColour(x, y, gw, gh); c != 0 {
+		return aurora.Colorize(g, c).String()
+	}
+	if c := t.activityColour(act, x, y); c != 0 {
+		return aurora.Colorize(g, c).String()
+	}
+	switch g {
+	case t.deadFiller, " ", string(rune(brailleBlank)):
+		return g
+	case "█":
+		if t.view.mode == RenderBlock {
+			return t.liveFiller
+		}
+	}
+	return aurora.Green(g).String()
+}
+
+//rawGlyph returns the uncoloured glyph with the top-left cell x,y
+//the zoomed out block glyphs are shaded by the live cells density
+func (t *ConsoleUI) rawGlyph(a universe.Area, x int, y int, s int) string {
+	switch t.view.mode {
+	case RenderHalf:
+		top := pixel(a, x, y, s)
+		bottom := pixel(a, x, y+s, s)
+		switch {
+		case top && bottom:
+			return "█"
+		case top:
+			return "▀"
+		case bottom:
+			return "▄"
+		default:
+			return " "
+		}
+	case RenderBraille:
+		r := rune(brailleBlank)
+		for py, dots := range brailleDots {
+			for px, dot := range dots {
+				if pixel(a, x+px*s, y+py*s, s) {
+					r |= dot
+				}
+			}
+		}
+		return string(r)
+	default:
+		live, total := density(a, x, y, s)
+		switch {
+		case live == 0:
+			return t.deadFiller
+		case live == total:
+			return "█"
+		case live*3 < total:
+			return "▒"
+		default:
+			return "▓"
+		}
+	}
+}
+
+//pixel reports whether any of s x s cells with the top-left cell x,y is alive, the cells outside the field are dead
+func pixel(a universe.Area, x int, y int, s int) bool {
+	if x >= a.Width || y >= a.Height {
+		return false
+	}
+	live, _ := density(a, x, y, s)
+	return live > 0
+}
@@ -0,0 +1,253 @@
+package view
+
+import (
+	"fmt"
+	"github.com/jroimartin/gocui"
+	"simlife/src/universe"
+)
+
+/*
+	The battle field viewport
+	the view shows the part of the field starting at the viewport origin, it's panned with the arrow keys or by mouse dragging,
+	the zoomed out view aggregates N x N cells to one glyph shaded by the live cells density,
+	the high density render modes pack several cells (pixels) to one glyph (see render.go),
+	the follow mode centres the view on the bounding box of the live cells
+	the viewport is used by the gocui goroutine only (the key handlers and the Update functions)
+*/
+
+var zoomLevels = []int{1, 2, 4, 8, 16} //the number of the cells aggregated to one glyph horizontally and vertically
+
+type viewport struct {
+	x       int //the field coordinates of the view top-left corner
+	y       int
+	zoom    int        //the index of the zoom level
+	mode    RenderMode //the glyphs of the field, the high density modes draw several cells by one glyph
+	follow  bool       //centre the view on the live cells
+	pressed bool       //the mouse button is pressed on the field
+	dragged bool       //the mouse is moved with the button pressed, the view is panned instead of the click
+	pressX  int        //the view position where the mouse button is pressed
+	pressY  int
+	mouseX  int //the last view position of the dragged mouse
+	mouseY  int
+}
+
+//scale returns the number of the cells aggregated to one glyph
+func (vp *viewport) scale() int {
+	return zoomLevels[vp.zoom]
+}
+
+//glyphSize returns the number of the cells covered by one glyph horizontally and vertically
+func (vp *viewport) glyphSize() (int, int) {
+	s := vp.scale()
+	px, py := vp.mode.pixels()
+	return px * s, py * s
+}
+
+//fit centres the view on the live cells in the follow mode and keeps the view inside the field
+//w and h are the view size in glyphs
+func (vp *viewport) fit(a universe.Area, w int, h int) {
+	gw, gh := vp.glyphSize()
+	if vp.follow {
+		if minX, minY, maxX, maxY, ok := liveBounds(a); ok {
+			vp.x = (minX+maxX+1)/2 - w*gw/2
+			vp.y = (minY+maxY+1)/2 - h*gh/2
+		}
+	}
+	vp.x = clamp(vp.x, 0, a.Width-w*gw)
+	vp.y = clamp(vp.y, 0, a.Height-h*gh)
+}
+
+//cell returns the field coordinates of the glyph at the view position, the top-left cell of the glyph
+//the mouse can't address the other cells of the glyph covering several cells (zoomed out or the high density modes),
+//so the click zooms in on the glyph (or moves the editor cursor there) instead of changing the cells, see single
+func (vp *viewport) cell(vx int, vy int) (int, int) {
+	gw, gh := vp.glyphSize()
+	return vp.x + vx*gw, vp.y + vy*gh
+}
+
+//single reports whether one glyph shows one cell, so the mouse addresses every cell
+func (vp *viewport) single() bool {
+	gw, gh := vp.glyphSize()
+	return gw == 1 && gh == 1
+}
+
+//zoomIn zooms in by one level keeping the glyph at the view position vx,vy under it,
+//the high density mode is switched to the block mode at the zoom 1:1
+func (vp *viewport) zoomIn(vx int, vy int) {
+	x, y := vp.cell(vx, vy)
+	if vp.zoom > 0 {
+		vp.zoom--
+	} else {
+		vp.mode = RenderBlock
+	}
+	gw, gh := vp.glyphSize()
+	vp.x, vp.y = x-vx*gw, y-vy*gh
+}
+
+//setZoom changes the zoom level keeping the view centre, w and h are the view size in glyphs
+func (vp *viewport) setZoom(zoom int, w int, h int) {
+	if zoom < 0 || zoom >= len(zoomLevels) {
+		return
+	}
+	gw, gh := vp.glyphSize()
+	cx, cy := vp.x+w*gw/2, vp.y+h*gh/2
+	vp.zoom = zoom
+	gw, gh = vp.glyphSize()
+	vp.x, vp.y = cx-w*gw/2, cy-h*gh/2
+}
+
+//setMode changes the render mode keeping the view centre, w and h are the view size in glyphs
+func (vp *viewport) setMode(mode RenderMode, w int, h int) {
+	gw, gh := vp.glyphSize()
+	cx, cy := vp.x+w*gw/2, vp.y+h*gh/2
+	vp.mode = mode
+	gw, gh = vp.glyphSize()
+	vp.x, vp.y = cx-w*gw/2, cy-h*gh/2
+}
+
+//title returns the battle field panel title describing the visible part of the field
+func (vp *viewport) title(a universe.Area, w int, h int) string {
+	s := vp.scale()
+	gw, gh := vp.glyphSize()
+	if !vp.follow && s == 1 && vp.mode == RenderBlock && a.Width <= w && a.Height <= h {
+		return "Battle Field"
+	}
+	title := fmt.Sprintf("Battle Field (%v,%v-%v,%v of %vx%v, zoom 1:%v",
+		vp.x, vp.y, min(vp.x+w*gw, a.Width)-1, min(vp.y+h*gh, a.Height)-1, a.Width, a.Height, s)
+	if vp.mode != RenderBlock {
+		title += ", " + vp.mode.String()
+	}
+	if vp.follow {
+		title += ", follow"
+	}
+	return title + ")"
+}
+
+//liveBounds returns the bounding box of the live cells
+func liveBounds(a universe.Area) (minX int, minY int, maxX int, maxY int, ok bool) {
+	for y, row := range a.Entities {
+		for x, e := range row {
+			if !e {
+				continue
+			}
+			if !ok {
+				minX, minY, maxX, maxY, ok = x, y, x, y, true
+				continue
+			}
+			minX, maxX = min(minX, x), max(maxX, x)
+			maxY = y
+		}
+	}
+	return
+}
+
+//density returns the number of the live cells and the number of the field cells in the glyph with the top-left cell x,y
+func density(a universe.Area, x int, y int, s int) (live int, total int) {
+	for cy := y; cy < y+s && cy < a.Height; cy++ {
+		row := a.Entities[cy]
+		for cx := x; cx < x+s && cx < a.Width; cx++ {
+			total++
+			if row[cx] {
+				live++
+			}
+		}
+	}
+	return
+}
+
+//cmdPan returns the gocui key handler panning the view by the quarter of the view size in the direction dx, dy
+func (t *ConsoleUI) cmdPan(dx int, dy int) func(v *gocui.View) error {
+	return func(v *gocui.View) error {
+		w, h := v.Size()
+		gw, gh := t.view.glyphSize()
+		t.view.follow = false
+		t.view.x += dx * max(w/4, 1) * gw
+		t.view.y += dy * max(h/4, 1) * gh
+		t.renderField(t.u.Area())
+		return nil
+	}
+}
+
+//cmdZoom returns the gocui key handler changing the zoom level by delta
+func (t *ConsoleUI) cmdZoom(delta int) func(v *gocui.View) error {
+	return func(v *gocui.View) error {
+		w, h := v.Size()
+		t.view.setZoom(t.view.zoom+delta, w, h)
+		t.renderField(t.u.Area())
+		return nil
+	}
+}
+
+//cmdRenderMode calls by gocui key handler and switches to the next render mode
+func (t *ConsoleUI) cmdRenderMode(v *gocui.View) error {
+	w, h := v.Size()
+	t.view.setMode(t.view.mode.next(), w, h)
+	t.renderField(t.u.Area())
+	return nil
+}
+
+//cmdFollow calls by gocui key handler and switches the follow the action mode
+func (t *ConsoleUI) cmdFollow(_ *gocui.View) error {
+	t.view.follow = !t.view.follow
+	t.renderField(t.u.Area())
+	return nil
+}
+
+//cmdMouseDown calls by gocui when the mouse button is pressed on the battle field, starts the click or the dragging
+//starts the drawing in the edit mode
+func (t *ConsoleUI) cmdMouseDown(v *gocui.View) error {
+	if t.editor.on {
+		return t.editorPress(v, false)
+	}
+	cx, cy := v.Cursor()
+	t.view.pressed, t.view.dragged = true, false
+	t.view.pressX, t.view.pressY = cx, cy
+	t.view.mouseX, t.view.mouseY = cx, cy
+	return nil
+}
+
+//cmdMouseDrag calls by gocui when the mouse is moved with the button pressed, pans the view or draws in the edit mode
+func (t *ConsoleUI) cmdMouseDrag(v *gocui.View) error {
+	if t.editor.on {
+		return t.editorDrag(v)
+	}
+	if !t.view.pressed {
+		return nil
+	}
+	cx, cy := v.Cursor()
+	if cx == t.view.mouseX && cy == t.view.mouseY {
+		return nil
+	}
+	gw, gh := t.view.glyphSize()
+	t.view.follow = false
+	t.view.dragged = true
+	t.view.x -= (cx - t.view.mouseX) * gw
+	t.view.y -= (cy - t.view.mouseY) * gh
+	t.view.mouseX, t.view.mouseY = cx, cy
+	t.renderField(t.u.Area())
+	return nil
+}
+
+func clamp(v int, lo int, hi int) int {
+	if v > hi {
+		v = hi
+	}
+	if v < lo {
+		v = lo
+	}
+	return v
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}